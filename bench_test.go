@@ -2,7 +2,9 @@ package ubjson
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"testing"
 )
 
@@ -543,3 +545,120 @@ func (v *benchValue) UnmarshalUBJSON(d *Decoder) error {
 
 	return o.End()
 }
+
+func BenchmarkDecoder_Skip_struct(b *testing.B) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	if err := e.Encode(&bs); err != nil {
+		b.Fatal(err)
+	}
+	bin := buf.Bytes()
+	r := bytes.NewReader(bin)
+	d := NewDecoder(r)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Reset(bin)
+		if err := d.Skip(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncoder_EncodeFloat32s(b *testing.B) {
+	v := make([]float32, 100000)
+	e := NewEncoder(io.Discard)
+	b.SetBytes(4 * int64(len(v)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.EncodeFloat32s(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder_DecodeFloat32s(b *testing.B) {
+	var buf bytes.Buffer
+	v := make([]float32, 100000)
+	if err := NewEncoder(&buf).EncodeFloat32s(v); err != nil {
+		b.Fatal(err)
+	}
+	bin := buf.Bytes()
+	r := bytes.NewReader(bin)
+	d := NewDecoder(r)
+	b.SetBytes(int64(len(bin)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(bin)
+		var err error
+		if v, err = d.DecodeFloat32s(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshal_struct(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(&bs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalBlock_struct(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalBlock(&bs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONMarshal_struct(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(&bs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_struct(b *testing.B) {
+	bin, err := Marshal(&bs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v benchStruct
+		if err := Unmarshal(bin, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONUnmarshal_struct(b *testing.B) {
+	bin, err := json.Marshal(&bs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v benchStruct
+		if err := json.Unmarshal(bin, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalNoCopy_struct(b *testing.B) {
+	bin, err := Marshal(&bs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v benchStruct
+		if err := UnmarshalNoCopy(bin, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
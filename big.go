@@ -0,0 +1,243 @@
+package ubjson
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Valid returns true if h conforms to the JSON number grammar.
+func (h HighPrecNumber) Valid() bool {
+	return validNumber(string(h))
+}
+
+// BigInt parses h as an integer. Exponents are permitted as long as the value
+// is integral, e.g. "1.5e3".
+func (h HighPrecNumber) BigInt() (*big.Int, error) {
+	s := string(h)
+	if !validNumber(s) {
+		return nil, fmt.Errorf("invalid high precision number: %q", s)
+	}
+	if !strings.ContainsAny(s, ".eE") {
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse integer: %q", s)
+		}
+		return i, nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse number: %q", s)
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("high precision number is not an integer: %q", s)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// BigFloat parses h as a float with at least enough precision to represent
+// each decimal digit.
+func (h HighPrecNumber) BigFloat() (*big.Float, error) {
+	return h.bigFloat(0)
+}
+
+// bigFloat parses h as a float with precision prec, or if prec is 0, at least
+// enough precision to represent each decimal digit.
+func (h HighPrecNumber) bigFloat(prec uint) (*big.Float, error) {
+	s := string(h)
+	if !validNumber(s) {
+		return nil, fmt.Errorf("invalid high precision number: %q", s)
+	}
+	if prec == 0 {
+		// log2(10) < 4 bits per digit.
+		prec = 4 * uint(len(s))
+		if prec < 64 {
+			prec = 64
+		}
+	}
+	f, _, err := big.ParseFloat(s, 10, prec, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse float: %w", err)
+	}
+	return f, nil
+}
+
+// BigRat parses h as an exact rational number.
+func (h HighPrecNumber) BigRat() (*big.Rat, error) {
+	s := string(h)
+	if !validNumber(s) {
+		return nil, fmt.Errorf("invalid high precision number: %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse number: %q", s)
+	}
+	return r, nil
+}
+
+// validNumber returns true if s conforms to the JSON number grammar.
+// Based on 'encoding/json/encode.go'.
+func validNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	// Optional -
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
+		}
+	}
+
+	// Digits
+	switch {
+	default:
+		return false
+
+	case s[0] == '0':
+		s = s[1:]
+
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// . followed by 1 or more digits.
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// e or E followed by an optional - or + and
+	// 1 or more digits.
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
+	}
+
+	// Make sure we are at the end.
+	return s == ""
+}
+
+// EncodeBigInt encodes a big.Int as a high precision number 'H'.
+func (e *Encoder) EncodeBigInt(v *big.Int) error {
+	return e.EncodeHighPrecNum(v.Text(10))
+}
+
+// EncodeBigFloat encodes a big.Float as a high precision number 'H', with the
+// minimum number of digits necessary to represent it exactly. Infinities are
+// not permitted.
+func (e *Encoder) EncodeBigFloat(v *big.Float) error {
+	if v.IsInf() {
+		return errors.New("unable to encode infinite big.Float")
+	}
+	return e.EncodeHighPrecNum(v.Text('g', -1))
+}
+
+// EncodeBigRat encodes a big.Rat as a high precision number 'H'. The value must
+// be representable by a finite decimal, i.e. the denominator may only have the
+// prime factors 2 and 5.
+func (e *Encoder) EncodeBigRat(v *big.Rat) error {
+	if v.IsInt() {
+		return e.EncodeHighPrecNum(v.Num().Text(10))
+	}
+	digits, ok := decimalDigits(v.Denom())
+	if !ok {
+		return fmt.Errorf("unable to encode big.Rat %s: not a finite decimal", v)
+	}
+	return e.EncodeHighPrecNum(v.FloatString(digits))
+}
+
+// decimalDigits returns the number of decimal digits required to represent the
+// fraction 1/denom exactly, or false if there is no finite representation.
+func decimalDigits(denom *big.Int) (int, bool) {
+	var twos, fives int
+	d := new(big.Int).Set(denom)
+	for d.Bit(0) == 0 {
+		d.Rsh(d, 1)
+		twos++
+	}
+	five, mod := big.NewInt(5), new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(d, five, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		d = q
+		fives++
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+// DecodeBigInt decodes a high precision number (H) or any integer value
+// (U,i,I,l,L) into a big.Int.
+func (d *Decoder) DecodeBigInt() (*big.Int, error) {
+	h, err := d.decodeBigNumber(bigIntType)
+	if err != nil {
+		return nil, d.locate(err)
+	}
+	return h.BigInt()
+}
+
+// DecodeBigFloat decodes a high precision number (H) or any integer value
+// (U,i,I,l,L) into a big.Float.
+func (d *Decoder) DecodeBigFloat() (*big.Float, error) {
+	h, err := d.decodeBigNumber(bigFloatType)
+	if err != nil {
+		return nil, d.locate(err)
+	}
+	return h.BigFloat()
+}
+
+// DecodeBigRat decodes a high precision number (H) or any integer value
+// (U,i,I,l,L) into a big.Rat.
+func (d *Decoder) DecodeBigRat() (*big.Rat, error) {
+	h, err := d.decodeBigNumber(bigRatType)
+	if err != nil {
+		return nil, d.locate(err)
+	}
+	return h.BigRat()
+}
+
+// decodeBigNumber decodes a high precision number (H) or any integer value
+// (U,i,I,l,L), as a HighPrecNumber to be converted to type t.
+func (d *Decoder) decodeBigNumber(t reflect.Type) (HighPrecNumber, error) {
+	m, err := d.readValType()
+	if err != nil {
+		return "", err
+	}
+	switch m {
+	case HighPrecNumMarker:
+		s, err := d.readString(d.MaxCollectionAlloc)
+		return HighPrecNumber(s), err
+	case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+		i, err := readIntData(d, m)
+		if err != nil {
+			return "", err
+		}
+		return HighPrecNumber(strconv.FormatInt(i, 10)), nil
+	}
+	return "", &UnmarshalTypeError{Marker: m, Type: t, msg: fmt.Sprintf("unable to decode big number from type marker: %s", m)}
+}
@@ -0,0 +1,148 @@
+package ubjson
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestBigRoundTrip(t *testing.T) {
+	i, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	f, _, _ := big.ParseFloat("3.14159265358979323846264338327950288", 10, 200, big.ToNearestEven)
+	r := big.NewRat(-5, 4)
+
+	type ledger struct {
+		Int   *big.Int
+		Float *big.Float
+		Rat   *big.Rat
+		Nil   *big.Int
+	}
+	in := ledger{Int: i, Float: f, Rat: r}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ledger
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Int.Cmp(i) != 0 {
+		t.Errorf("expected %s but got %s", i, out.Int)
+	}
+	// The decoded precision is derived from the digits, so compare decimals.
+	if exp, got := f.Text('g', -1), out.Float.Text('g', -1); exp != got {
+		t.Errorf("expected %s but got %s", exp, got)
+	}
+	if out.Rat.Cmp(r) != 0 {
+		t.Errorf("expected %s but got %s", r, out.Rat)
+	}
+	if out.Nil != nil {
+		t.Errorf("expected nil but got %s", out.Nil)
+	}
+}
+
+func TestUnmarshalBigFromInt(t *testing.T) {
+	for _, bin := range [][]byte{
+		{'U', 200},
+		{'i', 0x38},
+		{'I', 0x00, 0xC8},
+		{'l', 0x00, 0x00, 0x00, 0xC8},
+		{'L', 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC8},
+	} {
+		var i big.Int
+		if err := Unmarshal(bin, &i); err != nil {
+			t.Errorf("%q: %s", bin, err)
+		}
+		var r big.Rat
+		if err := Unmarshal(bin, &r); err != nil {
+			t.Errorf("%q: %s", bin, err)
+		}
+		if i.Int64() != 200 && i.Int64() != 56 {
+			t.Errorf("%q: unexpected big.Int %s", bin, &i)
+		}
+		if r.Num().Cmp(&i) != 0 {
+			t.Errorf("%q: expected big.Rat %s but got %s", bin, &i, &r)
+		}
+	}
+}
+
+func TestUnmarshalBigFloatPrec(t *testing.T) {
+	f := new(big.Float).SetPrec(24)
+	if err := Unmarshal([]byte{'H', 'U', 3, '0', '.', '1'}, f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Prec() != 24 {
+		t.Errorf("expected precision 24 but got %d", f.Prec())
+	}
+}
+
+func TestHighPrecNumber(t *testing.T) {
+	for _, tt := range []struct {
+		h     HighPrecNumber
+		valid bool
+		isInt bool
+	}{
+		{"0", true, true},
+		{"-12", true, true},
+		{"1.5e3", true, true},
+		{"1.25", true, false},
+		{"-0.5E-2", true, false},
+		{"", false, false},
+		{"-", false, false},
+		{"01", false, false},
+		{"1.", false, false},
+		{".5", false, false},
+		{"1e", false, false},
+		{"1e+", false, false},
+		{"NaN", false, false},
+		{"0x10", false, false},
+	} {
+		if v := tt.h.Valid(); v != tt.valid {
+			t.Errorf("%q: expected Valid %t but got %t", tt.h, tt.valid, v)
+		}
+		_, err := tt.h.BigInt()
+		if tt.isInt != (err == nil) {
+			t.Errorf("%q: unexpected BigInt error: %v", tt.h, err)
+		}
+		_, err = tt.h.BigRat()
+		if tt.valid != (err == nil) {
+			t.Errorf("%q: unexpected BigRat error: %v", tt.h, err)
+		}
+		_, err = tt.h.BigFloat()
+		if tt.valid != (err == nil) {
+			t.Errorf("%q: unexpected BigFloat error: %v", tt.h, err)
+		}
+	}
+}
+
+func TestEncoder_EncodeBigRat(t *testing.T) {
+	for _, tt := range []struct {
+		r   *big.Rat
+		exp string
+	}{
+		{big.NewRat(3, 1), "3"},
+		{big.NewRat(5, 4), "1.25"},
+		{big.NewRat(-1, 20), "-0.05"},
+		{big.NewRat(1, 3), ""},
+	} {
+		var buf bytes.Buffer
+		err := NewEncoder(&buf).EncodeBigRat(tt.r)
+		if tt.exp == "" {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.r, err)
+			continue
+		}
+		var h HighPrecNumber
+		if err := NewDecoder(&buf).Decode(&h); err != nil {
+			t.Fatal(err)
+		}
+		if string(h) != tt.exp {
+			t.Errorf("%s: expected %q but got %q", tt.r, tt.exp, h)
+		}
+	}
+}
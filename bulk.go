@@ -0,0 +1,507 @@
+package ubjson
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// bulkBufSize is the size of the buffer used to convert typed array payloads.
+const bulkBufSize = 512
+
+// EncodeBytes encodes a []byte as a strongly typed array of uint8 ([$U#).
+func (e *Encoder) EncodeBytes(v []byte) error {
+	return e.EncodeArray(func(e *Encoder) error { return e.writeBytes(v) })
+}
+
+// EncodeInt8s encodes an []int8 as a strongly typed array of int8 ([$i#).
+func (e *Encoder) EncodeInt8s(v []int8) error {
+	return e.EncodeArray(func(e *Encoder) error { return e.writeInt8s(v) })
+}
+
+// EncodeInt16s encodes an []int16 as a strongly typed array of int16 ([$I#).
+func (e *Encoder) EncodeInt16s(v []int16) error {
+	return e.EncodeArray(func(e *Encoder) error { return e.writeInt16s(v) })
+}
+
+// EncodeInt32s encodes an []int32 as a strongly typed array of int32 ([$l#).
+func (e *Encoder) EncodeInt32s(v []int32) error {
+	return e.EncodeArray(func(e *Encoder) error { return e.writeInt32s(v) })
+}
+
+// EncodeInt64s encodes an []int64 as a strongly typed array of int64 ([$L#).
+func (e *Encoder) EncodeInt64s(v []int64) error {
+	return e.EncodeArray(func(e *Encoder) error { return e.writeInt64s(v) })
+}
+
+// EncodeFloat32s encodes a []float32 as a strongly typed array of float32
+// ([$d#).
+func (e *Encoder) EncodeFloat32s(v []float32) error {
+	return e.EncodeArray(func(e *Encoder) error { return e.writeFloat32s(v) })
+}
+
+// EncodeFloat64s encodes a []float64 as a strongly typed array of float64
+// ([$D#).
+func (e *Encoder) EncodeFloat64s(v []float64) error {
+	return e.EncodeArray(func(e *Encoder) error { return e.writeFloat64s(v) })
+}
+
+func (e *Encoder) writeBytes(v []byte) error {
+	a, err := e.ArrayType(UInt8Marker, len(v))
+	if err != nil {
+		return err
+	}
+	if err := a.writeBulk(UInt8Marker, v); err != nil {
+		return err
+	}
+	a.count = a.len
+	return a.End()
+}
+
+func (e *Encoder) writeInt8s(v []int8) error {
+	return e.writeFixed(Int8Marker, len(v), func(b []byte, i int) {
+		b[0] = uint8(v[i])
+	})
+}
+
+func (e *Encoder) writeInt16s(v []int16) error {
+	return e.writeFixed(Int16Marker, len(v), func(b []byte, i int) {
+		binary.BigEndian.PutUint16(b, uint16(v[i]))
+	})
+}
+
+func (e *Encoder) writeInt32s(v []int32) error {
+	return e.writeFixed(Int32Marker, len(v), func(b []byte, i int) {
+		binary.BigEndian.PutUint32(b, uint32(v[i]))
+	})
+}
+
+func (e *Encoder) writeInt64s(v []int64) error {
+	return e.writeFixed(Int64Marker, len(v), func(b []byte, i int) {
+		binary.BigEndian.PutUint64(b, uint64(v[i]))
+	})
+}
+
+func (e *Encoder) writeFloat32s(v []float32) error {
+	return e.writeFixed(Float32Marker, len(v), func(b []byte, i int) {
+		binary.BigEndian.PutUint32(b, math.Float32bits(v[i]))
+	})
+}
+
+func (e *Encoder) writeFloat64s(v []float64) error {
+	return e.writeFixed(Float64Marker, len(v), func(b []byte, i int) {
+		binary.BigEndian.PutUint64(b, math.Float64bits(v[i]))
+	})
+}
+
+// writeFixed writes a strongly typed array container of n elements of fixed
+// size type m, following the start marker. The big-endian payload is
+// converted in chunks via put, which must write element i to b.
+func (e *Encoder) writeFixed(m Marker, n int, put func(b []byte, i int)) error {
+	a, err := e.ArrayType(m, n)
+	if err != nil {
+		return err
+	}
+	size, _ := fixedSize(m)
+	var buf [bulkBufSize]byte
+	for i := 0; i < n; {
+		c := 0
+		for ; i < n && c+size <= len(buf); i++ {
+			put(buf[c:c+size], i)
+			c += size
+		}
+		if err := a.writeBulk(m, buf[:c]); err != nil {
+			return err
+		}
+	}
+	a.count = a.len
+	return a.End()
+}
+
+// DecodeBytes decodes an array of uint8 values into dst, reusing its capacity
+// if possible, and returns the result. Strongly typed arrays ([$U#) are read
+// in bulk.
+func (d *Decoder) DecodeBytes(dst []byte) ([]byte, error) {
+	return dst, d.DecodeArray(func(a *ArrayDecoder) error {
+		var err error
+		dst, err = a.readBytes(dst)
+		return err
+	})
+}
+
+// DecodeInt8s decodes an array of int8 values into dst, reusing its capacity
+// if possible, and returns the result. Strongly typed arrays ([$i#) are read
+// in bulk.
+func (d *Decoder) DecodeInt8s(dst []int8) ([]int8, error) {
+	return dst, d.DecodeArray(func(a *ArrayDecoder) error {
+		var err error
+		dst, err = a.readInt8s(dst)
+		return err
+	})
+}
+
+// DecodeInt16s decodes an array of int16 values into dst, reusing its
+// capacity if possible, and returns the result. Strongly typed arrays ([$I#)
+// are read in bulk.
+func (d *Decoder) DecodeInt16s(dst []int16) ([]int16, error) {
+	return dst, d.DecodeArray(func(a *ArrayDecoder) error {
+		var err error
+		dst, err = a.readInt16s(dst)
+		return err
+	})
+}
+
+// DecodeInt32s decodes an array of int32 values into dst, reusing its
+// capacity if possible, and returns the result. Strongly typed arrays ([$l#)
+// are read in bulk.
+func (d *Decoder) DecodeInt32s(dst []int32) ([]int32, error) {
+	return dst, d.DecodeArray(func(a *ArrayDecoder) error {
+		var err error
+		dst, err = a.readInt32s(dst)
+		return err
+	})
+}
+
+// DecodeInt64s decodes an array of int64 values into dst, reusing its
+// capacity if possible, and returns the result. Strongly typed arrays ([$L#)
+// are read in bulk.
+func (d *Decoder) DecodeInt64s(dst []int64) ([]int64, error) {
+	return dst, d.DecodeArray(func(a *ArrayDecoder) error {
+		var err error
+		dst, err = a.readInt64s(dst)
+		return err
+	})
+}
+
+// DecodeFloat32s decodes an array of float32 values into dst, reusing its
+// capacity if possible, and returns the result. Strongly typed arrays ([$d#)
+// are read in bulk.
+func (d *Decoder) DecodeFloat32s(dst []float32) ([]float32, error) {
+	return dst, d.DecodeArray(func(a *ArrayDecoder) error {
+		var err error
+		dst, err = a.readFloat32s(dst)
+		return err
+	})
+}
+
+// DecodeFloat64s decodes an array of float64 values into dst, reusing its
+// capacity if possible, and returns the result. Strongly typed arrays ([$D#)
+// are read in bulk.
+func (d *Decoder) DecodeFloat64s(dst []float64) ([]float64, error) {
+	return dst, d.DecodeArray(func(a *ArrayDecoder) error {
+		var err error
+		dst, err = a.readFloat64s(dst)
+		return err
+	})
+}
+
+// bulkLen returns the length of a strongly typed array of type m, which may
+// be read in bulk, or false if a must be read element by element.
+func (a *ArrayDecoder) bulkLen(m Marker) (int, bool, error) {
+	if a.ElemType != m || a.count > 0 {
+		return 0, false, nil
+	}
+	if a.Len > a.MaxCollectionAlloc {
+		return 0, false, errMaxAlloc(a.MaxCollectionAlloc, a.Len)
+	}
+	if err := a.addElements(a.Len); err != nil {
+		return 0, false, err
+	}
+	return a.Len, true, nil
+}
+
+// readFixed reads the payload of a strongly typed array of fixed size type m,
+// and ends the array. The big-endian payload is converted in chunks via get,
+// which must read element i from b.
+func (a *ArrayDecoder) readFixed(m Marker, get func(b []byte, i int)) error {
+	size, _ := fixedSize(m)
+	var buf [bulkBufSize]byte
+	for i := 0; i < a.Len; {
+		c := (a.Len - i) * size
+		if c > len(buf) {
+			c = len(buf) / size * size
+		}
+		b := buf[:c]
+		if err := a.readBulk(m, b); err != nil {
+			return err
+		}
+		for ; len(b) > 0; i++ {
+			get(b[:size], i)
+			b = b[size:]
+		}
+	}
+	a.count = a.Len
+	return a.End()
+}
+
+func (a *ArrayDecoder) readBytes(dst []byte) ([]byte, error) {
+	if l, ok, err := a.bulkLen(UInt8Marker); err != nil {
+		return dst, err
+	} else if ok {
+		if r, ok := a.reader.(*bytesReader); ok && r.noCopy {
+			// Alias the input.
+			b, err := r.next(l)
+			if err != nil {
+				return dst, fmt.Errorf("failed to read %d bytes: %w", l, err)
+			}
+			dst = b[:l:l]
+		} else {
+			dst = resizeBytes(dst, l)
+			if err := a.readBulk(UInt8Marker, dst); err != nil {
+				return dst, err
+			}
+		}
+		a.count = a.Len
+		return dst, a.End()
+	}
+	dst = dst[:0]
+	for a.NextElem() {
+		v, err := a.DecodeUInt8()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, a.End()
+}
+
+func (a *ArrayDecoder) readInt8s(dst []int8) ([]int8, error) {
+	if l, ok, err := a.bulkLen(Int8Marker); err != nil {
+		return dst, err
+	} else if ok {
+		if cap(dst) < l {
+			dst = make([]int8, l)
+		}
+		dst = dst[:l]
+		return dst, a.readFixed(Int8Marker, func(b []byte, i int) {
+			dst[i] = int8(b[0])
+		})
+	}
+	dst = dst[:0]
+	for a.NextElem() {
+		v, err := a.DecodeInt8()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, a.End()
+}
+
+func (a *ArrayDecoder) readInt16s(dst []int16) ([]int16, error) {
+	if l, ok, err := a.bulkLen(Int16Marker); err != nil {
+		return dst, err
+	} else if ok {
+		if cap(dst) < l {
+			dst = make([]int16, l)
+		}
+		dst = dst[:l]
+		return dst, a.readFixed(Int16Marker, func(b []byte, i int) {
+			dst[i] = int16(binary.BigEndian.Uint16(b))
+		})
+	}
+	dst = dst[:0]
+	for a.NextElem() {
+		v, err := a.DecodeInt16()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, a.End()
+}
+
+func (a *ArrayDecoder) readInt32s(dst []int32) ([]int32, error) {
+	if l, ok, err := a.bulkLen(Int32Marker); err != nil {
+		return dst, err
+	} else if ok {
+		if cap(dst) < l {
+			dst = make([]int32, l)
+		}
+		dst = dst[:l]
+		return dst, a.readFixed(Int32Marker, func(b []byte, i int) {
+			dst[i] = int32(binary.BigEndian.Uint32(b))
+		})
+	}
+	dst = dst[:0]
+	for a.NextElem() {
+		v, err := a.DecodeInt32()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, a.End()
+}
+
+func (a *ArrayDecoder) readInt64s(dst []int64) ([]int64, error) {
+	if l, ok, err := a.bulkLen(Int64Marker); err != nil {
+		return dst, err
+	} else if ok {
+		if cap(dst) < l {
+			dst = make([]int64, l)
+		}
+		dst = dst[:l]
+		return dst, a.readFixed(Int64Marker, func(b []byte, i int) {
+			dst[i] = int64(binary.BigEndian.Uint64(b))
+		})
+	}
+	dst = dst[:0]
+	for a.NextElem() {
+		v, err := a.DecodeInt64()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, a.End()
+}
+
+func (a *ArrayDecoder) readFloat32s(dst []float32) ([]float32, error) {
+	if l, ok, err := a.bulkLen(Float32Marker); err != nil {
+		return dst, err
+	} else if ok {
+		if cap(dst) < l {
+			dst = make([]float32, l)
+		}
+		dst = dst[:l]
+		return dst, a.readFixed(Float32Marker, func(b []byte, i int) {
+			dst[i] = math.Float32frombits(binary.BigEndian.Uint32(b))
+		})
+	}
+	dst = dst[:0]
+	for a.NextElem() {
+		v, err := a.DecodeFloat32()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, a.End()
+}
+
+func (a *ArrayDecoder) readFloat64s(dst []float64) ([]float64, error) {
+	if l, ok, err := a.bulkLen(Float64Marker); err != nil {
+		return dst, err
+	} else if ok {
+		if cap(dst) < l {
+			dst = make([]float64, l)
+		}
+		dst = dst[:l]
+		return dst, a.readFixed(Float64Marker, func(b []byte, i int) {
+			dst[i] = math.Float64frombits(binary.BigEndian.Uint64(b))
+		})
+	}
+	dst = dst[:0]
+	for a.NextElem() {
+		v, err := a.DecodeFloat64()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, a.End()
+}
+
+// resizeBytes returns b with length l, reallocating if necessary.
+func resizeBytes(b []byte, l int) []byte {
+	if cap(b) < l {
+		return make([]byte, l)
+	}
+	return b[:l]
+}
+
+var (
+	bytesType    = reflect.TypeOf([]byte(nil))
+	int8sType    = reflect.TypeOf([]int8(nil))
+	int16sType   = reflect.TypeOf([]int16(nil))
+	int32sType   = reflect.TypeOf([]int32(nil))
+	int64sType   = reflect.TypeOf([]int64(nil))
+	float32sType = reflect.TypeOf([]float32(nil))
+	float64sType = reflect.TypeOf([]float64(nil))
+)
+
+// bulkSliceType returns the unnamed slice type of sliceType, if it has a
+// builtin fixed size numeric element type, which may be encoded in bulk.
+func bulkSliceType(sliceType reflect.Type) (reflect.Type, bool) {
+	switch reflect.SliceOf(sliceType.Elem()) {
+	case bytesType:
+		return bytesType, true
+	case int8sType:
+		return int8sType, true
+	case int16sType:
+		return int16sType, true
+	case int32sType:
+		return int32sType, true
+	case int64sType:
+		return int64sType, true
+	case float32sType:
+		return float32sType, true
+	case float64sType:
+		return float64sType, true
+	}
+	return nil, false
+}
+
+// writeSlice writes sliceValue in bulk, following the start marker, or
+// returns false if it does not have a bulk type.
+func (e *Encoder) writeSlice(sliceValue reflect.Value) (bool, error) {
+	t, ok := bulkSliceType(sliceValue.Type())
+	if !ok {
+		return false, nil
+	}
+	// The element types are identical, or differ only by name.
+	p, n := sliceValue.UnsafePointer(), sliceValue.Len()
+	switch t {
+	case bytesType:
+		return true, e.writeBytes(unsafe.Slice((*byte)(p), n))
+	case int8sType:
+		return true, e.writeInt8s(unsafe.Slice((*int8)(p), n))
+	case int16sType:
+		return true, e.writeInt16s(unsafe.Slice((*int16)(p), n))
+	case int32sType:
+		return true, e.writeInt32s(unsafe.Slice((*int32)(p), n))
+	case int64sType:
+		return true, e.writeInt64s(unsafe.Slice((*int64)(p), n))
+	case float32sType:
+		return true, e.writeFloat32s(unsafe.Slice((*float32)(p), n))
+	case float64sType:
+		return true, e.writeFloat64s(unsafe.Slice((*float64)(p), n))
+	}
+	return false, nil
+}
+
+// readSlice reads into sliceValue in bulk, or returns false if it does not have
+// a bulk type.
+func (a *ArrayDecoder) readSlice(sliceValue reflect.Value) (bool, error) {
+	t, ok := bulkSliceType(sliceValue.Type())
+	if !ok {
+		return false, nil
+	}
+	var v interface{}
+	var err error
+	switch t {
+	// Empty slices are decoded as non-nil.
+	case bytesType:
+		v, err = a.readBytes([]byte{})
+	case int8sType:
+		v, err = a.readInt8s([]int8{})
+	case int16sType:
+		v, err = a.readInt16s([]int16{})
+	case int32sType:
+		v, err = a.readInt32s([]int32{})
+	case int64sType:
+		v, err = a.readInt64s([]int64{})
+	case float32sType:
+		v, err = a.readFloat32s([]float32{})
+	case float64sType:
+		v, err = a.readFloat64s([]float64{})
+	}
+	if err != nil {
+		return true, err
+	}
+	sliceValue.Set(reflect.ValueOf(v).Convert(sliceValue.Type()))
+	return true, nil
+}
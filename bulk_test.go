@@ -0,0 +1,122 @@
+package ubjson
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBulkRoundTrip(t *testing.T) {
+	type frame []float32
+	for _, v := range []interface{}{
+		[]byte{0, 1, 255},
+		[]int8{math.MinInt8, 0, math.MaxInt8},
+		[]int16{math.MinInt16, 0, math.MaxInt16},
+		[]int32{math.MinInt32, 0, math.MaxInt32},
+		[]int64{math.MinInt64, 0, math.MaxInt64},
+		[]float32{-1.5, 0, math.MaxFloat32},
+		[]float64{-1.5, 0, 2.25},
+		frame{1, 2, 3},
+		make([]float64, 1000),
+		[]int16{},
+	} {
+		typ := reflect.TypeOf(v)
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("%s: %s", typ, err)
+		}
+		if m := elementMarkerFor(typ.Elem()); b[2] != byte(m) {
+			t.Errorf("%s: expected strongly typed array of %s but got %q", typ, m, b[:3])
+		}
+		got := reflect.New(typ)
+		if err := Unmarshal(b, got.Interface()); err != nil {
+			t.Fatalf("%s: %s", typ, err)
+		}
+		if !reflect.DeepEqual(v, got.Elem().Interface()) {
+			t.Errorf("%s: expected %v but got %v", typ, v, got.Elem().Interface())
+		}
+
+		block, err := MarshalBlock(v)
+		if err != nil {
+			t.Fatalf("%s: %s", typ, err)
+		}
+		got = reflect.New(typ)
+		if err := UnmarshalBlock(block, got.Interface()); err != nil {
+			t.Fatalf("%s: %s", typ, err)
+		}
+		if !reflect.DeepEqual(v, got.Elem().Interface()) {
+			t.Errorf("%s: expected %v from block but got %v", typ, v, got.Elem().Interface())
+		}
+	}
+}
+
+func TestDecoder_DecodeFloat32s(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	in := []float32{1, 2, 3, 4}
+	if err := e.EncodeFloat32s(in); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeFloat32s(in[:2]); err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{'[', '$', 'd', '#', 'U', 4,
+		0x3F, 0x80, 0, 0, 0x40, 0, 0, 0, 0x40, 0x40, 0, 0, 0x40, 0x80, 0, 0,
+		'[', '$', 'd', '#', 'U', 2,
+		0x3F, 0x80, 0, 0, 0x40, 0, 0, 0}
+	if !bytes.Equal(buf.Bytes(), exp) {
+		t.Fatalf("expected:\n%v\nbut got:\n%v", exp, buf.Bytes())
+	}
+
+	d := NewDecoder(&buf)
+	dst := make([]float32, 0, 8)
+	got, err := d.DecodeFloat32s(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, got) {
+		t.Errorf("expected %v but got %v", in, got)
+	}
+	if &got[0] != &dst[:1][0] {
+		t.Error("expected dst to be reused")
+	}
+	got, err = d.DecodeFloat32s(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in[:2], got) {
+		t.Errorf("expected %v but got %v", in[:2], got)
+	}
+}
+
+func TestDecoder_DecodeInt32s_fallback(t *testing.T) {
+	for _, bin := range [][]byte{
+		// Untyped.
+		{'[', 'U', 1, 'i', 0xFE, 'l', 0, 0, 1, 0, ']'},
+		// Narrower type.
+		{'[', '$', 'I', '#', 'U', 3, 0, 1, 0xFF, 0xFE, 1, 0},
+	} {
+		got, err := NewDecoder(bytes.NewReader(bin)).DecodeInt32s(nil)
+		if err != nil {
+			t.Fatalf("%q: %s", bin, err)
+		}
+		if exp := []int32{1, -2, 256}; !reflect.DeepEqual(exp, got) {
+			t.Errorf("%q: expected %v but got %v", bin, exp, got)
+		}
+	}
+
+	d := NewDecoder(bytes.NewReader([]byte{'[', '$', 'L', '#', 'U', 1, 0, 0, 0, 1, 0, 0, 0, 0}))
+	if _, err := d.DecodeInt32s(nil); err == nil {
+		t.Error("expected overflow decoding int64 array into []int32")
+	}
+}
+
+func TestDecoder_DecodeBytes_limit(t *testing.T) {
+	d := NewDecoder(bytes.NewReader([]byte{'[', '$', 'U', '#', 'l', 0x7F, 0xFF, 0xFF, 0xFF}))
+	d.MaxCollectionAlloc = 1024
+	if _, err := d.DecodeBytes(nil); err == nil || !strings.Contains(err.Error(), "max allocation") {
+		t.Errorf("expected max allocation error but got: %v", err)
+	}
+}
@@ -0,0 +1,939 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const ubjsonPath = "github.com/jmank88/ubjson"
+
+// generate returns the formatted source of a file declaring the ubjson.Value
+// methods of the named struct types from the package in dir. The args are
+// recorded in the header comment.
+func generate(dir string, typeNames []string, outName, args string) ([]byte, error) {
+	pkg, checkErr, err := loadPackage(dir, outName)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{
+		pkg:       pkg,
+		checkErr:  checkErr,
+		imports:   map[string]string{},
+		generated: map[*types.Named]bool{},
+	}
+
+	var named []*types.Named
+	for _, name := range typeNames {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in package %s", name, pkg.Name())
+		}
+		t, ok := obj.Type().(*types.Named)
+		if !ok || obj.IsAlias() {
+			return nil, fmt.Errorf("%s is not a defined type", name)
+		}
+		if _, ok := t.Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("%s is not a struct type", name)
+		}
+		if t.TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("generic type %s is not supported", name)
+		}
+		named = append(named, t)
+		g.generated[t] = true
+	}
+	for _, t := range named {
+		if err := g.genType(t); err != nil {
+			return nil, err
+		}
+	}
+	return g.source(args)
+}
+
+// loadPackage parses and type checks the package in dir, excluding the file
+// exclude, which may be a stale output file. Type checking errors do not
+// prevent generation, since the package may depend on the methods to be
+// generated, so the first is returned separately.
+func loadPackage(dir, exclude string) (*types.Package, error, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	exclude, err = filepath.Abs(exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		path := filepath.Join(dir, name)
+		if abs, err := filepath.Abs(path); err == nil && abs == exclude {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+
+	var checkErr error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if checkErr == nil {
+				checkErr = err
+			}
+		},
+	}
+	pkg, _ := conf.Check(bp.Name, fset, files, nil)
+	if pkg == nil {
+		return nil, nil, fmt.Errorf("failed to type check package %s: %w", dir, checkErr)
+	}
+	return pkg, checkErr, nil
+}
+
+// A generator accumulates the methods of each type, and the imports they
+// require.
+type generator struct {
+	pkg      *types.Package
+	checkErr error
+	buf      bytes.Buffer
+	// Import names by path.
+	imports map[string]string
+	// Types which will implement ubjson.Value once generated.
+	generated map[*types.Named]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// source returns the formatted file.
+func (g *generator) source(args string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"ubjsongen %s\"; DO NOT EDIT.\n\n", args)
+	fmt.Fprintf(&b, "package %s\n\n", g.pkg.Name())
+
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	b.WriteString("import (\n")
+	for _, path := range std {
+		fmt.Fprintf(&b, "%q\n", path)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		b.WriteString("\n")
+	}
+	for _, path := range other {
+		fmt.Fprintf(&b, "%q\n", path)
+	}
+	b.WriteString(")\n\n")
+	b.Write(g.buf.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated source: %w\n%s", err, b.Bytes())
+	}
+	return src, nil
+}
+
+// qualifier records the import of p, unless it is the generated package, and
+// returns its name.
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	g.imports[p.Path()] = p.Name()
+	return p.Name()
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+// use records the import of the standard library package path, and returns
+// its name.
+func (g *generator) use(path string) string {
+	name := path[strings.LastIndex(path, "/")+1:]
+	g.imports[path] = name
+	return name
+}
+
+// ubjson returns the qualified name of an identifier from the ubjson package.
+func (g *generator) ubjson(name string) string {
+	g.imports[ubjsonPath] = "ubjson"
+	return "ubjson." + name
+}
+
+// convert returns x converted to type to, if t is not identical.
+func (g *generator) convert(x string, t, to types.Type) string {
+	if types.Identical(t, to) {
+		return x
+	}
+	return g.typeString(to) + "(" + x + ")"
+}
+
+// A field describes a struct field to be encoded, following the rules of the
+// ubjson package.
+type field struct {
+	// Name, from the 'ubjson' struct tag if present.
+	name string
+	// Whether the name came from the 'ubjson' struct tag.
+	tag bool
+	// Index sequence of the field, through any embedded structs.
+	index []int
+	// Fields selected from the receiver, through any embedded structs.
+	path []*types.Var
+	// Type of the field, or of the pointed to struct for embedded pointers.
+	typ types.Type
+	// Omit the field when encoding if it has an empty value.
+	omitEmpty bool
+	// Encode the scalar value as a string.
+	asString bool
+	// Name of the ubjson.TimeFormat constant overriding the TimeFormat, if any.
+	timeFormat string
+}
+
+// expr returns the selector expression of the first n fields of f.path.
+func (f *field) expr(n int) string {
+	var b strings.Builder
+	b.WriteString("v")
+	for _, v := range f.path[:n] {
+		b.WriteString(".")
+		b.WriteString(v.Name())
+	}
+	return b.String()
+}
+
+// fieldType returns the declared type of the field.
+func (f *field) fieldType() types.Type {
+	return f.path[len(f.path)-1].Type()
+}
+
+// timeFormatOptions are the 'ubjson' struct tag options for each TimeFormat
+// constant.
+var timeFormatOptions = [...]struct{ opt, name string }{
+	{"text", "TimeText"},
+	{"nanos", "TimeNanos"},
+	{"seconds", "TimeSeconds"},
+}
+
+// typeFields returns the fields to encode for the struct type t, like the
+// ubjson package does via reflection: indexed by 'ubjson' struct tag if
+// present, otherwise name, and with the fields of embedded structs promoted
+// following Go's visibility rules, as amended by the tags.
+// Based on 'encoding/json/encode.go'.
+func (g *generator) typeFields(t types.Type) ([]field, error) {
+	// Anonymous fields to explore at the current level and the next.
+	var current []field
+	next := []field{{typ: t}}
+
+	// Count of queued names for current level and the next.
+	var count, nextCount map[types.Type]int
+
+	// Types already visited at an earlier level.
+	visited := map[types.Type]bool{}
+
+	var list []field
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[types.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			st := f.typ.Underlying().(*types.Struct)
+			for i := 0; i < st.NumFields(); i++ {
+				sf := st.Field(i)
+				if g.checkErr != nil && strings.Contains(types.TypeString(sf.Type(), nil), "invalid type") {
+					return nil, fmt.Errorf("unable to resolve type of field %s: %w", sf.Name(), g.checkErr)
+				}
+				if sf.Embedded() {
+					if !sf.Exported() && !isStruct(derefType(sf.Type())) {
+						// Ignore embedded fields of unexported non-struct types.
+						continue
+					}
+					// Do not ignore embedded fields of unexported struct types
+					// since they may have exported fields.
+				} else if !sf.Exported() {
+					// Ignore unexported non-embedded fields.
+					continue
+				}
+				tag := reflect.StructTag(st.Tag(i)).Get("ubjson")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+				path := make([]*types.Var, len(f.path)+1)
+				copy(path, f.path)
+				path[len(f.path)] = sf
+
+				ft := sf.Type()
+				if p, ok := ft.(*types.Pointer); ok {
+					// Follow pointer.
+					ft = p.Elem()
+				}
+
+				// Record found field and index sequence.
+				if name != "" || !sf.Embedded() || !isStruct(ft) {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name()
+					}
+					fld := field{
+						name:      name,
+						tag:       tagged,
+						index:     index,
+						path:      path,
+						typ:       ft,
+						omitEmpty: hasOption(opts, "omitempty"),
+					}
+					for _, tf := range timeFormatOptions {
+						if hasOption(opts, tf.opt) {
+							fld.timeFormat = tf.name
+						}
+					}
+					if hasOption(opts, "string") {
+						if b, ok := derefType(sf.Type()).Underlying().(*types.Basic); ok && b.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat) != 0 {
+							fld.asString = true
+						}
+					}
+					list = append(list, fld)
+					if count[f.typ] > 1 {
+						// If there were multiple instances, add a second,
+						// so that the annihilation code will see a duplicate.
+						list = append(list, list[len(list)-1])
+					}
+					continue
+				}
+
+				// Record new anonymous struct to explore in next round.
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: sf.Name(), index: index, path: path, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		x := list
+		// Sort field by name, breaking ties with depth, then breaking ties
+		// with "name came from ubjson tag", then breaking ties with index
+		// sequence.
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tag != x[j].tag {
+			return x[i].tag
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	// Delete all fields that are hidden by the Go rules for embedded fields,
+	// except that fields with ubjson tags are promoted.
+	out := list[:0]
+	for advance, i := 0, 0; i < len(list); i += advance {
+		// One iteration per name.
+		// Find the sequence of fields with the name of this first field.
+		fi := list[i]
+		for advance = 1; i+advance < len(list); advance++ {
+			if list[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		// The first field is dominant, unless there are two at the same depth
+		// which are either both tagged or neither tagged.
+		if len(fi.index) != len(list[i+1].index) || fi.tag != list[i+1].tag {
+			out = append(out, fi)
+		}
+	}
+	list = out
+
+	sort.Slice(list, func(i, j int) bool {
+		return indexLess(list[i].index, list[j].index)
+	})
+	return list, nil
+}
+
+// indexLess reports whether index sequence a sorts before b.
+func indexLess(a, b []int) bool {
+	for k, ak := range a {
+		if k >= len(b) {
+			return false
+		}
+		if ak != b[k] {
+			return ak < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// hasOption reports whether a comma-separated list of options contains a
+// particular option.
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var name string
+		name, opts, _ = strings.Cut(opts, ",")
+		if name == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isStruct(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+func derefType(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// genType generates the methods of t.
+func (g *generator) genType(t *types.Named) error {
+	fs, err := g.typeFields(t)
+	if err != nil {
+		return fmt.Errorf("failed to generate %s: %w", t.Obj().Name(), err)
+	}
+	name := t.Obj().Name()
+	g.printf("// UBJSONType implements ubjson.Value.\n")
+	g.printf("func (v *%s) UBJSONType() %s {\nreturn %s\n}\n\n", name, g.ubjson("Marker"), g.ubjson("ObjectStartMarker"))
+	g.genMarshal(name, fs)
+	g.genUnmarshal(name, fs)
+	return nil
+}
+
+func (g *generator) genMarshal(name string, fs []field) {
+	g.printf("// MarshalUBJSON implements ubjson.Value.\n")
+	g.printf("func (v *%s) MarshalUBJSON(e *%s) error {\n", name, g.ubjson("Encoder"))
+
+	// The count is known ahead of time, less any omitted fields.
+	conds := make([]string, len(fs))
+	fixed, hasTimeFormat := 0, false
+	for i := range fs {
+		conds[i] = g.presentCond(&fs[i])
+		if conds[i] == "" {
+			fixed++
+		}
+		hasTimeFormat = hasTimeFormat || fs[i].timeFormat != "" && !fs[i].asString
+	}
+	if fixed == len(fs) {
+		g.printf("o, err := e.ObjectLen(%d)\n", fixed)
+	} else {
+		g.printf("n := %d\n", fixed)
+		for _, cond := range conds {
+			if cond != "" {
+				g.printf("if %s {\nn++\n}\n", cond)
+			}
+		}
+		g.printf("o, err := e.ObjectLen(n)\n")
+	}
+	g.printf("if err != nil {\nreturn err\n}\n")
+	if hasTimeFormat {
+		g.printf("tf := o.TimeFormat\n")
+	}
+
+	for i := range fs {
+		f := &fs[i]
+		if conds[i] != "" {
+			g.printf("if %s {\n", conds[i])
+		}
+		g.printf("if err := o.EncodeKey(%q); err != nil {\nreturn err\n}\n", f.name)
+		x := f.expr(len(f.path))
+		if f.asString {
+			g.encodeString(f.fieldType(), x)
+		} else if f.timeFormat != "" {
+			g.printf("o.TimeFormat = %s\n", g.ubjson(f.timeFormat))
+			g.encodeValue("o", f.fieldType(), x)
+			g.printf("o.TimeFormat = tf\n")
+		} else {
+			g.encodeValue("o", f.fieldType(), x)
+		}
+		if conds[i] != "" {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return o.End()\n}\n\n")
+}
+
+// presentCond returns the condition for encoding f, or the empty string if it
+// is always encoded: it must not be reachable through nil embedded pointers,
+// or empty with the omitempty option.
+func (g *generator) presentCond(f *field) string {
+	var conds []string
+	for i, v := range f.path[:len(f.path)-1] {
+		if _, ok := v.Type().(*types.Pointer); ok {
+			conds = append(conds, f.expr(i+1)+" != nil")
+		}
+	}
+	if f.omitEmpty {
+		if c := nonEmptyCond(f.fieldType(), f.expr(len(f.path))); c != "" {
+			conds = append(conds, c)
+		}
+	}
+	return strings.Join(conds, " && ")
+}
+
+// nonEmptyCond returns the condition for x of type t to be non-empty, for the
+// purposes of the omitempty option, or the empty string if it never is.
+func nonEmptyCond(t types.Type, x string) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsBoolean != 0:
+			return x
+		case info&types.IsString != 0:
+			return x + ` != ""`
+		case info&(types.IsInteger|types.IsFloat) != 0:
+			return x + " != 0"
+		}
+	case *types.Slice, *types.Map:
+		return "len(" + x + ") != 0"
+	case *types.Array:
+		if u.Len() == 0 {
+			return "false"
+		}
+	case *types.Pointer, *types.Interface:
+		return x + " != nil"
+	}
+	return ""
+}
+
+// A scalar describes the Encoder and Decoder methods for a type.
+type scalar struct {
+	// Method name suffixes.
+	enc, dec string
+	// Type of the encoded and decoded value.
+	typ types.Type
+	// Name of the strong type marker constant for containers, or empty.
+	marker string
+}
+
+var basicScalars = map[types.BasicKind]scalar{
+	types.String:  {"String", "String", types.Typ[types.String], "StringMarker"},
+	types.Bool:    {"Bool", "Bool", types.Typ[types.Bool], ""},
+	types.Int:     {"Int", "Int", types.Typ[types.Int], ""},
+	types.Int8:    {"Int8", "Int8", types.Typ[types.Int8], "Int8Marker"},
+	types.Int16:   {"Int16", "Int16", types.Typ[types.Int16], "Int16Marker"},
+	types.Int32:   {"Int32", "Int32", types.Typ[types.Int32], "Int32Marker"},
+	types.Int64:   {"Int64", "Int64", types.Typ[types.Int64], "Int64Marker"},
+	types.Uint:    {"UInt", "UInt", types.Typ[types.Uint], ""},
+	types.Uint8:   {"UInt8", "UInt8", types.Typ[types.Uint8], "UInt8Marker"},
+	types.Uint16:  {"UInt16", "UInt16", types.Typ[types.Uint16], ""},
+	types.Uint32:  {"UInt32", "UInt32", types.Typ[types.Uint32], ""},
+	types.Uint64:  {"UInt64", "UInt64", types.Typ[types.Uint64], ""},
+	types.Float32: {"Float32", "Float32", types.Typ[types.Float32], "Float32Marker"},
+	types.Float64: {"Float64", "Float64", types.Typ[types.Float64], "Float64Marker"},
+}
+
+// bulkSlices are the Encoder and Decoder method name suffixes for slices
+// written in bulk, by element kind.
+var bulkSlices = map[types.BasicKind]string{
+	types.Uint8:   "Bytes",
+	types.Int8:    "Int8s",
+	types.Int16:   "Int16s",
+	types.Int32:   "Int32s",
+	types.Int64:   "Int64s",
+	types.Float32: "Float32s",
+	types.Float64: "Float64s",
+}
+
+// A kind is the category of generated code for a type.
+type kind int
+
+const (
+	// Encoded via the generic Encode and Decode methods.
+	kindOther kind = iota
+	kindValue
+	kindScalar
+	kindBulk
+	kindArray
+	kindMap
+)
+
+// classify returns the kind of t, along with the scalar describing t, or its
+// elements for containers. The precedence follows the ubjson package.
+func (g *generator) classify(t types.Type) (kind, scalar) {
+	if g.isValue(t) {
+		return kindValue, scalar{}
+	}
+	switch {
+	case isNamed(t, "time", "Time"):
+		return kindScalar, scalar{"Time", "Time", t, ""}
+	case isNamed(t, "time", "Duration"):
+		return kindScalar, scalar{"Duration", "Duration", t, ""}
+	}
+	for _, m := range []string{"MarshalText", "MarshalBinary", "UnmarshalText", "UnmarshalBinary"} {
+		if hasMethod(t, m) {
+			return kindOther, scalar{}
+		}
+	}
+	switch {
+	case isNamed(t, ubjsonPath, "Char"):
+		return kindScalar, scalar{"Char", "Char", types.Typ[types.Byte], "CharMarker"}
+	case isNamed(t, ubjsonPath, "HighPrecNumber"):
+		return kindScalar, scalar{"HighPrecNum", "HighPrecNumber", types.Typ[types.String], "HighPrecNumMarker"}
+	case isNamed(t, ubjsonPath, "Number"):
+		return kindScalar, scalar{"Number", "Number", t, ""}
+	case isNamed(t, ubjsonPath, "RawValue"):
+		return kindScalar, scalar{"RawValue", "RawValue", t, ""}
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if s, ok := basicScalars[u.Kind()]; ok {
+			return kindScalar, s
+		}
+	case *types.Slice:
+		if b, ok := u.Elem().(*types.Basic); ok {
+			if name, ok := bulkSlices[b.Kind()]; ok {
+				elem := types.Typ[b.Kind()]
+				if b.Kind() == types.Uint8 {
+					elem = types.Typ[types.Byte]
+				}
+				return kindBulk, scalar{name, name, types.NewSlice(elem), ""}
+			}
+		}
+		if k, s := g.classify(u.Elem()); k == kindScalar {
+			return kindArray, s
+		}
+	case *types.Array:
+		if k, s := g.classify(u.Elem()); k == kindScalar {
+			return kindArray, s
+		}
+	case *types.Map:
+		if b, ok := u.Key().Underlying().(*types.Basic); !ok || b.Kind() != types.String || hasMethod(u.Key(), "UnmarshalText") {
+			break
+		}
+		if k, s := g.classify(u.Elem()); k == kindScalar {
+			return kindMap, s
+		}
+	}
+	return kindOther, scalar{}
+}
+
+// isValue returns true if a pointer to t implements ubjson.Value, or will once
+// generated.
+func (g *generator) isValue(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	if g.generated[n] {
+		return true
+	}
+	switch n.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		return false
+	}
+	return hasMethod(t, "UBJSONType") && hasMethod(t, "MarshalUBJSON") && hasMethod(t, "UnmarshalUBJSON")
+}
+
+// isNamed returns true if t is the named type path.name.
+func isNamed(t types.Type, path, name string) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == path && obj.Name() == name
+}
+
+// hasMethod returns true if t or *t has the method name.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// encodeValue generates code encoding x of type t with the encoder r.
+func (g *generator) encodeValue(r string, t types.Type, x string) {
+	k, s := g.classify(t)
+	switch k {
+	case kindValue:
+		g.printf("if err := %s.EncodeValue(&%s); err != nil {\nreturn err\n}\n", r, x)
+	case kindScalar, kindBulk:
+		g.printf("if err := %s.Encode%s(%s); err != nil {\nreturn err\n}\n", r, s.enc, g.convert(x, t, s.typ))
+	case kindArray:
+		elem := t.Underlying().(interface{ Elem() types.Type }).Elem()
+		g.printf("if err := %s.EncodeArray(func(e *%s) error {\n", r, g.ubjson("Encoder"))
+		if s.marker != "" {
+			g.printf("a, err := e.ArrayType(%s, len(%s))\n", g.ubjson(s.marker), x)
+		} else {
+			g.printf("a, err := e.ArrayLen(len(%s))\n", x)
+		}
+		g.printf("if err != nil {\nreturn err\n}\n")
+		g.printf("for i := range %s {\n", x)
+		g.printf("if err := a.Encode%s(%s); err != nil {\nreturn err\n}\n", s.enc, g.convert(x+"[i]", elem, s.typ))
+		g.printf("}\nreturn a.End()\n")
+		g.printf("}); err != nil {\nreturn err\n}\n")
+	case kindMap:
+		m := t.Underlying().(*types.Map)
+		g.printf("if err := %s.EncodeObject(func(e *%s) error {\n", r, g.ubjson("Encoder"))
+		if s.marker != "" {
+			g.printf("m, err := e.ObjectType(%s, len(%s))\n", g.ubjson(s.marker), x)
+		} else {
+			g.printf("m, err := e.ObjectLen(len(%s))\n", x)
+		}
+		g.printf("if err != nil {\nreturn err\n}\n")
+		key := g.convert("k", m.Key(), types.Typ[types.String])
+		g.printf("if !e.SortMapKeys {\n")
+		g.printf("for k, x := range %s {\n", x)
+		g.printf("if err := m.EncodeKey(%s); err != nil {\nreturn err\n}\n", key)
+		g.printf("if err := m.Encode%s(%s); err != nil {\nreturn err\n}\n", s.enc, g.convert("x", m.Elem(), s.typ))
+		g.printf("}\nreturn m.End()\n}\n")
+		g.printf("keys := make([]string, 0, len(%s))\n", x)
+		g.printf("for k := range %s {\nkeys = append(keys, %s)\n}\n", x, key)
+		g.printf("%s.Strings(keys)\n", g.use("sort"))
+		g.printf("for _, k := range keys {\n")
+		g.printf("if err := m.EncodeKey(k); err != nil {\nreturn err\n}\n")
+		elem := fmt.Sprintf("%s[%s]", x, g.convert("k", types.Typ[types.String], m.Key()))
+		g.printf("if err := m.Encode%s(%s); err != nil {\nreturn err\n}\n", s.enc, g.convert(elem, m.Elem(), s.typ))
+		g.printf("}\nreturn m.End()\n")
+		g.printf("}); err != nil {\nreturn err\n}\n")
+	default:
+		g.printf("if err := %s.Encode(&%s); err != nil {\nreturn err\n}\n", r, x)
+	}
+}
+
+// encodeString generates code encoding the scalar x of type t as a string, for
+// fields with the 'string' option.
+func (g *generator) encodeString(t types.Type, x string) {
+	if p, ok := t.(*types.Pointer); ok {
+		g.printf("if %s == nil {\nif err := o.EncodeNull(); err != nil {\nreturn err\n}\n} else {\n", x)
+		g.encodeString(p.Elem(), "*"+x)
+		g.printf("}\n")
+		return
+	}
+	strconv := g.use("strconv")
+	var s string
+	switch b := t.Underlying().(*types.Basic); {
+	case b.Info()&types.IsBoolean != 0:
+		s = fmt.Sprintf("%s.FormatBool(bool(%s))", strconv, x)
+	case b.Info()&types.IsUnsigned != 0:
+		s = fmt.Sprintf("%s.FormatUint(uint64(%s), 10)", strconv, x)
+	case b.Info()&types.IsInteger != 0:
+		s = fmt.Sprintf("%s.FormatInt(int64(%s), 10)", strconv, x)
+	default:
+		s = fmt.Sprintf("%s.FormatFloat(float64(%s), 'g', -1, %d)", strconv, x, floatBits(b))
+	}
+	g.printf("if err := o.EncodeString(%s); err != nil {\nreturn err\n}\n", s)
+}
+
+func floatBits(b *types.Basic) int {
+	if b.Kind() == types.Float32 {
+		return 32
+	}
+	return 64
+}
+
+func (g *generator) genUnmarshal(name string, fs []field) {
+	g.printf("// UnmarshalUBJSON implements ubjson.Value.\n")
+	g.printf("func (v *%s) UnmarshalUBJSON(d *%s) error {\n", name, g.ubjson("Decoder"))
+	g.printf("o, err := d.Object()\nif err != nil {\nreturn err\n}\n")
+	for i := range fs {
+		if fs[i].timeFormat != "" && !fs[i].asString {
+			g.printf("tf := o.TimeFormat\n")
+			break
+		}
+	}
+	g.printf("for o.NextEntry() {\n")
+	g.printf("k, err := o.DecodeKey()\nif err != nil {\nreturn err\n}\n")
+	foldName := "ubjsonFold" + strings.ToUpper(name[:1]) + name[1:]
+	if len(fs) > 0 {
+		g.printf("if o.CaseInsensitiveFields {\nk = %s(k)\n}\n", foldName)
+	}
+	g.printf("switch k {\n")
+	for i := range fs {
+		f := &fs[i]
+		g.printf("case %q:\n", f.name)
+		// Allocate nil embedded pointers.
+		for j, v := range f.path[:len(f.path)-1] {
+			if p, ok := v.Type().(*types.Pointer); ok {
+				x := f.expr(j + 1)
+				g.printf("if %s == nil {\n%s = new(%s)\n}\n", x, x, g.typeString(p.Elem()))
+			}
+		}
+		x := f.expr(len(f.path))
+		if f.asString {
+			g.decodeString(f.fieldType(), x)
+		} else if f.timeFormat != "" {
+			g.printf("o.TimeFormat = %s\n", g.ubjson(f.timeFormat))
+			g.decodeValue("o", f.fieldType(), x)
+			g.printf("o.TimeFormat = tf\n")
+		} else {
+			g.decodeValue("o", f.fieldType(), x)
+		}
+	}
+	g.printf("default:\n")
+	g.printf("if o.DisallowUnknownFields {\nreturn &%s{Key: k, Type: %s.TypeOf(v).Elem()}\n}\n",
+		g.ubjson("UnknownFieldError"), g.use("reflect"))
+	g.printf("if err := o.Skip(); err != nil {\nreturn err\n}\n")
+	g.printf("}\n}\nreturn o.End()\n}\n\n")
+
+	if len(fs) == 0 {
+		return
+	}
+	names := make([]string, len(fs))
+	for i := range fs {
+		names[i] = fmt.Sprintf("%q", fs[i].name)
+	}
+	// Long lists are written one name per line.
+	cases, elems := strings.Join(names, ", "), strings.Join(names, ", ")
+	if len(cases) > 60 {
+		cases = strings.Join(names, ",\n")
+		elems = "\n" + cases + ",\n"
+	}
+	g.printf("// %s returns the name of the field of %s which k matches\n", foldName, name)
+	g.printf("// case-insensitively, if there is no exact match.\n")
+	g.printf("func %s(k string) string {\n", foldName)
+	g.printf("switch k {\ncase %s:\nreturn k\n}\n", cases)
+	g.printf("for _, name := range [...]string{%s} {\n", elems)
+	g.printf("if %s.EqualFold(k, name) {\nreturn name\n}\n}\n", g.use("strings"))
+	g.printf("return k\n}\n\n")
+}
+
+// decodeValue generates code decoding into x of type t with the decoder r.
+func (g *generator) decodeValue(r string, t types.Type, x string) {
+	k, s := g.classify(t)
+	switch k {
+	case kindValue:
+		g.printf("if err := %s.DecodeValue(&%s); err != nil {\nreturn err\n}\n", r, x)
+	case kindScalar:
+		g.printf("x, err := %s.Decode%s()\nif err != nil {\nreturn err\n}\n", r, s.dec)
+		g.printf("%s = %s\n", x, g.convert("x", s.typ, t))
+	case kindBulk:
+		// Empty slices are decoded as non-nil.
+		g.printf("x, err := %s.Decode%s(%s{})\nif err != nil {\nreturn err\n}\n", r, s.dec, g.typeString(s.typ))
+		g.printf("%s = %s\n", x, g.convert("x", s.typ, t))
+	case kindArray:
+		elem := t.Underlying().(interface{ Elem() types.Type }).Elem()
+		g.printf("if err := %s.DecodeArray(func(a *%s) error {\n", r, g.ubjson("ArrayDecoder"))
+		if arr, ok := t.Underlying().(*types.Array); ok {
+			g.printf("if a.Len > 0 && a.Len != %d {\n", arr.Len())
+			g.printf("return %s.Errorf(\"unable to decode data length %%d into array of length %d\", a.Len)\n}\n", g.use("fmt"), arr.Len())
+			g.printf("for i := range %s {\n", x)
+			g.printf("x, err := a.Decode%s()\nif err != nil {\nreturn err\n}\n", s.dec)
+			g.printf("%s[i] = %s\n}\n", x, g.convert("x", s.typ, elem))
+		} else {
+			typ := g.typeString(t)
+			g.printf("if a.Len > a.MaxCollectionAlloc {\n")
+			g.printf("return &%s{Limit: \"MaxCollectionAlloc\", Max: int64(a.MaxCollectionAlloc), Err: %s.Errorf(\"collection exceeds max allocation limit of %%d: %%d\", a.MaxCollectionAlloc, a.Len)}\n}\n", g.ubjson("LimitError"), g.use("fmt"))
+			g.printf("s := %s{}\nif a.Len > 0 {\ns = make(%s, 0, a.Len)\n}\n", typ, typ)
+			g.printf("for a.NextElem() {\n")
+			g.printf("x, err := a.Decode%s()\nif err != nil {\nreturn err\n}\n", s.dec)
+			g.printf("s = append(s, %s)\n}\n", g.convert("x", s.typ, elem))
+			g.printf("%s = s\n", x)
+		}
+		g.printf("return a.End()\n")
+		g.printf("}); err != nil {\nreturn err\n}\n")
+	case kindMap:
+		m := t.Underlying().(*types.Map)
+		typ := g.typeString(t)
+		g.printf("if err := %s.DecodeObject(func(m *%s) error {\n", r, g.ubjson("ObjectDecoder"))
+		g.printf("if m.Len > m.MaxCollectionAlloc {\n")
+		g.printf("return &%s{Limit: \"MaxCollectionAlloc\", Max: int64(m.MaxCollectionAlloc), Err: %s.Errorf(\"collection exceeds max allocation limit of %%d: %%d\", m.MaxCollectionAlloc, m.Len)}\n}\n", g.ubjson("LimitError"), g.use("fmt"))
+		g.printf("var mp %s\nif m.Len > 0 {\nmp = make(%s, m.Len)\n} else {\nmp = make(%s)\n}\n", typ, typ, typ)
+		g.printf("for m.NextEntry() {\n")
+		g.printf("k, err := m.DecodeKey()\nif err != nil {\nreturn err\n}\n")
+		g.printf("x, err := m.Decode%s()\nif err != nil {\nreturn err\n}\n", s.dec)
+		g.printf("mp[%s] = %s\n}\n", g.convert("k", types.Typ[types.String], m.Key()), g.convert("x", s.typ, m.Elem()))
+		g.printf("%s = mp\nreturn m.End()\n", x)
+		g.printf("}); err != nil {\nreturn err\n}\n")
+	default:
+		g.printf("if err := %s.Decode(&%s); err != nil {\nreturn err\n}\n", r, x)
+	}
+}
+
+// decodeString generates code decoding a string into the scalar x of type t,
+// for fields with the 'string' option.
+func (g *generator) decodeString(t types.Type, x string) {
+	if p, ok := t.(*types.Pointer); ok {
+		// Null sets the pointer to nil.
+		g.printf("var s *string\nif err := o.Decode(&s); err != nil {\nreturn err\n}\n")
+		g.printf("if s == nil {\n%s = nil\n} else {\n", x)
+		to := g.parseString(p.Elem(), "*s")
+		g.printf("y := %s\n%s = &y\n}\n", g.convert("x", to, p.Elem()), x)
+		return
+	}
+	g.printf("s, err := o.DecodeString()\nif err != nil {\nreturn err\n}\n")
+	to := g.parseString(t, "s")
+	g.printf("%s = %s\n", x, g.convert("x", to, t))
+}
+
+// parseString generates code parsing the string s into x, for a field of type t,
+// and returns the type of x.
+func (g *generator) parseString(t types.Type, s string) types.Type {
+	strconv := g.use("strconv")
+	var to types.Type
+	switch b := t.Underlying().(*types.Basic); {
+	case b.Info()&types.IsBoolean != 0:
+		g.printf("x, err := %s.ParseBool(%s)\n", strconv, s)
+		to = types.Typ[types.Bool]
+	case b.Info()&types.IsUnsigned != 0:
+		g.printf("x, err := %s.ParseUint(%s, 10, %d)\n", strconv, s, intBits(b))
+		to = types.Typ[types.Uint64]
+	case b.Info()&types.IsInteger != 0:
+		g.printf("x, err := %s.ParseInt(%s, 10, %d)\n", strconv, s, intBits(b))
+		to = types.Typ[types.Int64]
+	default:
+		g.printf("x, err := %s.ParseFloat(%s, %d)\n", strconv, s, floatBits(b))
+		to = types.Typ[types.Float64]
+	}
+	g.printf("if err != nil {\nreturn err\n}\n")
+	return to
+}
+
+// intBits returns the bit size of the integer type b, or 0 for the platform
+// dependent int, uint, and uintptr.
+func intBits(b *types.Basic) int {
+	switch b.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32:
+		return 32
+	case types.Int64, types.Uint64:
+		return 64
+	}
+	return 0
+}
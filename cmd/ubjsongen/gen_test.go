@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerate_gentest checks that the generated file in internal/gentest,
+// which is tested against the reflective encoding, is up to date.
+func TestGenerate_gentest(t *testing.T) {
+	dir := filepath.Join("internal", "gentest")
+	outName := filepath.Join(dir, "all_ubjson.go")
+	exp, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(dir, []string{"All", "Inner", "Empty"}, outName, "-type All,Inner,Empty -output all_ubjson.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exp, got) {
+		t.Errorf("%s is out of date; run go generate", outName)
+	}
+}
+
+func TestGenerate_errors(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+type S struct{ A int }
+
+type I int
+
+type G[T any] struct{ A T }
+`
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n\ngo 1.20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outName := filepath.Join(dir, "s_ubjson.go")
+
+	for name, exp := range map[string]string{
+		"X": "type X not found",
+		"I": "I is not a struct type",
+		"G": "generic type G is not supported",
+	} {
+		_, err := generate(dir, []string{name}, outName, "-type "+name)
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.Contains(err.Error(), exp) {
+			t.Errorf("%s: expected error containing %q but got: %v", name, exp, err)
+		}
+	}
+
+	got, err := generate(dir, []string{"S"}, outName, "-type S")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(got, []byte("func (v *S) MarshalUBJSON(e *ubjson.Encoder) error {")) {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
@@ -0,0 +1,1449 @@
+// Code generated by "ubjsongen -type All,Inner,Empty -output all_ubjson.go"; DO NOT EDIT.
+
+package gentest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmank88/ubjson"
+)
+
+// UBJSONType implements ubjson.Value.
+func (v *All) UBJSONType() ubjson.Marker {
+	return ubjson.ObjectStartMarker
+}
+
+// MarshalUBJSON implements ubjson.Value.
+func (v *All) MarshalUBJSON(e *ubjson.Encoder) error {
+	n := 58
+	if v.Omitted != "" {
+		n++
+	}
+	if len(v.OmitSlice) != 0 {
+		n++
+	}
+	if v.OmitPtr != nil {
+		n++
+	}
+	if v.EmbeddedPtr != nil {
+		n++
+	}
+	if v.EmbeddedPtr != nil && v.EmbeddedPtr.P2 != 0 {
+		n++
+	}
+	o, err := e.ObjectLen(n)
+	if err != nil {
+		return err
+	}
+	tf := o.TimeFormat
+	if err := o.EncodeKey("String"); err != nil {
+		return err
+	}
+	if err := o.EncodeString(v.String); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Bool"); err != nil {
+		return err
+	}
+	if err := o.EncodeBool(v.Bool); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt(v.Int); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int8"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt8(v.Int8); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int16"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt16(v.Int16); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int32"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt32(v.Int32); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int64"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt64(v.Int64); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Uint"); err != nil {
+		return err
+	}
+	if err := o.EncodeUInt(v.Uint); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Uint8"); err != nil {
+		return err
+	}
+	if err := o.EncodeUInt8(v.Uint8); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Uint16"); err != nil {
+		return err
+	}
+	if err := o.EncodeUInt16(v.Uint16); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Uint32"); err != nil {
+		return err
+	}
+	if err := o.EncodeUInt32(v.Uint32); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Uint64"); err != nil {
+		return err
+	}
+	if err := o.EncodeUInt64(v.Uint64); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Float32"); err != nil {
+		return err
+	}
+	if err := o.EncodeFloat32(v.Float32); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Float64"); err != nil {
+		return err
+	}
+	if err := o.EncodeFloat64(v.Float64); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Name"); err != nil {
+		return err
+	}
+	if err := o.EncodeString(string(v.Name)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Flag"); err != nil {
+		return err
+	}
+	if err := o.EncodeBool(bool(v.Flag)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Small"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt8(int8(v.Small)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Count"); err != nil {
+		return err
+	}
+	if err := o.EncodeUInt16(uint16(v.Count)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Ratio"); err != nil {
+		return err
+	}
+	if err := o.EncodeFloat32(float32(v.Ratio)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Char"); err != nil {
+		return err
+	}
+	if err := o.EncodeChar(uint8(v.Char)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("HighPrec"); err != nil {
+		return err
+	}
+	if err := o.EncodeHighPrecNum(string(v.HighPrec)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Number"); err != nil {
+		return err
+	}
+	if err := o.EncodeNumber(v.Number); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Raw"); err != nil {
+		return err
+	}
+	if err := o.EncodeRawValue(v.Raw); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Time"); err != nil {
+		return err
+	}
+	if err := o.EncodeTime(v.Time); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Nanos"); err != nil {
+		return err
+	}
+	o.TimeFormat = ubjson.TimeNanos
+	if err := o.EncodeTime(v.Nanos); err != nil {
+		return err
+	}
+	o.TimeFormat = tf
+	if err := o.EncodeKey("Duration"); err != nil {
+		return err
+	}
+	o.TimeFormat = ubjson.TimeSeconds
+	if err := o.EncodeDuration(v.Duration); err != nil {
+		return err
+	}
+	o.TimeFormat = tf
+	if err := o.EncodeKey("Bytes"); err != nil {
+		return err
+	}
+	if err := o.EncodeBytes(v.Bytes); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int8s"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt8s(v.Int8s); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int16s"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt16s(v.Int16s); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int32s"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt32s(v.Int32s); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Int64s"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt64s(v.Int64s); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Float32s"); err != nil {
+		return err
+	}
+	if err := o.EncodeFloat32s(v.Float32s); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Float64s"); err != nil {
+		return err
+	}
+	if err := o.EncodeFloat64s(v.Float64s); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Named"); err != nil {
+		return err
+	}
+	if err := o.EncodeBytes([]uint8(v.Named)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Strings"); err != nil {
+		return err
+	}
+	if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+		a, err := e.ArrayType(ubjson.StringMarker, len(v.Strings))
+		if err != nil {
+			return err
+		}
+		for i := range v.Strings {
+			if err := a.EncodeString(v.Strings[i]); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Names"); err != nil {
+		return err
+	}
+	if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+		a, err := e.ArrayType(ubjson.StringMarker, len(v.Names))
+		if err != nil {
+			return err
+		}
+		for i := range v.Names {
+			if err := a.EncodeString(v.Names[i]); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Smalls"); err != nil {
+		return err
+	}
+	if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+		a, err := e.ArrayType(ubjson.Int8Marker, len(v.Smalls))
+		if err != nil {
+			return err
+		}
+		for i := range v.Smalls {
+			if err := a.EncodeInt8(int8(v.Smalls[i])); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Chars"); err != nil {
+		return err
+	}
+	if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+		a, err := e.ArrayType(ubjson.CharMarker, len(v.Chars))
+		if err != nil {
+			return err
+		}
+		for i := range v.Chars {
+			if err := a.EncodeChar(uint8(v.Chars[i])); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Uints"); err != nil {
+		return err
+	}
+	if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+		a, err := e.ArrayLen(len(v.Uints))
+		if err != nil {
+			return err
+		}
+		for i := range v.Uints {
+			if err := a.EncodeUInt(v.Uints[i]); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Array"); err != nil {
+		return err
+	}
+	if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+		a, err := e.ArrayType(ubjson.Int16Marker, len(v.Array))
+		if err != nil {
+			return err
+		}
+		for i := range v.Array {
+			if err := a.EncodeInt16(v.Array[i]); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Times"); err != nil {
+		return err
+	}
+	if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+		a, err := e.ArrayLen(len(v.Times))
+		if err != nil {
+			return err
+		}
+		for i := range v.Times {
+			if err := a.EncodeTime(v.Times[i]); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Map"); err != nil {
+		return err
+	}
+	if err := o.EncodeObject(func(e *ubjson.Encoder) error {
+		m, err := e.ObjectType(ubjson.Int32Marker, len(v.Map))
+		if err != nil {
+			return err
+		}
+		if !e.SortMapKeys {
+			for k, x := range v.Map {
+				if err := m.EncodeKey(k); err != nil {
+					return err
+				}
+				if err := m.EncodeInt32(x); err != nil {
+					return err
+				}
+			}
+			return m.End()
+		}
+		keys := make([]string, 0, len(v.Map))
+		for k := range v.Map {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := m.EncodeKey(k); err != nil {
+				return err
+			}
+			if err := m.EncodeInt32(v.Map[k]); err != nil {
+				return err
+			}
+		}
+		return m.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Labels"); err != nil {
+		return err
+	}
+	if err := o.EncodeObject(func(e *ubjson.Encoder) error {
+		m, err := e.ObjectLen(len(v.Labels))
+		if err != nil {
+			return err
+		}
+		if !e.SortMapKeys {
+			for k, x := range v.Labels {
+				if err := m.EncodeKey(string(k)); err != nil {
+					return err
+				}
+				if err := m.EncodeUInt16(uint16(x)); err != nil {
+					return err
+				}
+			}
+			return m.End()
+		}
+		keys := make([]string, 0, len(v.Labels))
+		for k := range v.Labels {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := m.EncodeKey(k); err != nil {
+				return err
+			}
+			if err := m.EncodeUInt16(uint16(v.Labels[Name(k)])); err != nil {
+				return err
+			}
+		}
+		return m.End()
+	}); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("renamed"); err != nil {
+		return err
+	}
+	if err := o.EncodeString(v.Renamed); err != nil {
+		return err
+	}
+	if v.Omitted != "" {
+		if err := o.EncodeKey("Omitted"); err != nil {
+			return err
+		}
+		if err := o.EncodeString(v.Omitted); err != nil {
+			return err
+		}
+	}
+	if len(v.OmitSlice) != 0 {
+		if err := o.EncodeKey("OmitSlice"); err != nil {
+			return err
+		}
+		if err := o.EncodeArray(func(e *ubjson.Encoder) error {
+			a, err := e.ArrayLen(len(v.OmitSlice))
+			if err != nil {
+				return err
+			}
+			for i := range v.OmitSlice {
+				if err := a.EncodeInt(v.OmitSlice[i]); err != nil {
+					return err
+				}
+			}
+			return a.End()
+		}); err != nil {
+			return err
+		}
+	}
+	if v.OmitPtr != nil {
+		if err := o.EncodeKey("OmitPtr"); err != nil {
+			return err
+		}
+		if err := o.Encode(&v.OmitPtr); err != nil {
+			return err
+		}
+	}
+	if err := o.EncodeKey("Quoted"); err != nil {
+		return err
+	}
+	if err := o.EncodeString(strconv.FormatInt(int64(v.Quoted), 10)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("QuotedF"); err != nil {
+		return err
+	}
+	if err := o.EncodeString(strconv.FormatFloat(float64(v.QuotedF), 'g', -1, 32)); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("QuotedB"); err != nil {
+		return err
+	}
+	if err := o.EncodeString(strconv.FormatBool(bool(v.QuotedB))); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("QuotedP"); err != nil {
+		return err
+	}
+	if v.QuotedP == nil {
+		if err := o.EncodeNull(); err != nil {
+			return err
+		}
+	} else {
+		if err := o.EncodeString(strconv.FormatInt(int64(*v.QuotedP), 10)); err != nil {
+			return err
+		}
+	}
+	if err := o.EncodeKey("QuotedN"); err != nil {
+		return err
+	}
+	if v.QuotedN == nil {
+		if err := o.EncodeNull(); err != nil {
+			return err
+		}
+	} else {
+		if err := o.EncodeString(strconv.FormatBool(bool(*v.QuotedN))); err != nil {
+			return err
+		}
+	}
+	if err := o.EncodeKey("Inner"); err != nil {
+		return err
+	}
+	if err := o.EncodeValue(&v.Inner); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("InnerPtr"); err != nil {
+		return err
+	}
+	if err := o.Encode(&v.InnerPtr); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Inners"); err != nil {
+		return err
+	}
+	if err := o.Encode(&v.Inners); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Any"); err != nil {
+		return err
+	}
+	if err := o.Encode(&v.Any); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Big"); err != nil {
+		return err
+	}
+	if err := o.Encode(&v.Big); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Struct"); err != nil {
+		return err
+	}
+	if err := o.Encode(&v.Struct); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("Objects"); err != nil {
+		return err
+	}
+	if err := o.Encode(&v.Objects); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("E1"); err != nil {
+		return err
+	}
+	if err := o.EncodeString(v.Embedded.E1); err != nil {
+		return err
+	}
+	if err := o.EncodeKey("e2"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt(v.Embedded.E2); err != nil {
+		return err
+	}
+	if v.EmbeddedPtr != nil {
+		if err := o.EncodeKey("P1"); err != nil {
+			return err
+		}
+		if err := o.EncodeString(v.EmbeddedPtr.P1); err != nil {
+			return err
+		}
+	}
+	if v.EmbeddedPtr != nil && v.EmbeddedPtr.P2 != 0 {
+		if err := o.EncodeKey("P2"); err != nil {
+			return err
+		}
+		if err := o.EncodeInt(v.EmbeddedPtr.P2); err != nil {
+			return err
+		}
+	}
+	return o.End()
+}
+
+// UnmarshalUBJSON implements ubjson.Value.
+func (v *All) UnmarshalUBJSON(d *ubjson.Decoder) error {
+	o, err := d.Object()
+	if err != nil {
+		return err
+	}
+	tf := o.TimeFormat
+	for o.NextEntry() {
+		k, err := o.DecodeKey()
+		if err != nil {
+			return err
+		}
+		if o.CaseInsensitiveFields {
+			k = ubjsonFoldAll(k)
+		}
+		switch k {
+		case "String":
+			x, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			v.String = x
+		case "Bool":
+			x, err := o.DecodeBool()
+			if err != nil {
+				return err
+			}
+			v.Bool = x
+		case "Int":
+			x, err := o.DecodeInt()
+			if err != nil {
+				return err
+			}
+			v.Int = x
+		case "Int8":
+			x, err := o.DecodeInt8()
+			if err != nil {
+				return err
+			}
+			v.Int8 = x
+		case "Int16":
+			x, err := o.DecodeInt16()
+			if err != nil {
+				return err
+			}
+			v.Int16 = x
+		case "Int32":
+			x, err := o.DecodeInt32()
+			if err != nil {
+				return err
+			}
+			v.Int32 = x
+		case "Int64":
+			x, err := o.DecodeInt64()
+			if err != nil {
+				return err
+			}
+			v.Int64 = x
+		case "Uint":
+			x, err := o.DecodeUInt()
+			if err != nil {
+				return err
+			}
+			v.Uint = x
+		case "Uint8":
+			x, err := o.DecodeUInt8()
+			if err != nil {
+				return err
+			}
+			v.Uint8 = x
+		case "Uint16":
+			x, err := o.DecodeUInt16()
+			if err != nil {
+				return err
+			}
+			v.Uint16 = x
+		case "Uint32":
+			x, err := o.DecodeUInt32()
+			if err != nil {
+				return err
+			}
+			v.Uint32 = x
+		case "Uint64":
+			x, err := o.DecodeUInt64()
+			if err != nil {
+				return err
+			}
+			v.Uint64 = x
+		case "Float32":
+			x, err := o.DecodeFloat32()
+			if err != nil {
+				return err
+			}
+			v.Float32 = x
+		case "Float64":
+			x, err := o.DecodeFloat64()
+			if err != nil {
+				return err
+			}
+			v.Float64 = x
+		case "Name":
+			x, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			v.Name = Name(x)
+		case "Flag":
+			x, err := o.DecodeBool()
+			if err != nil {
+				return err
+			}
+			v.Flag = Flag(x)
+		case "Small":
+			x, err := o.DecodeInt8()
+			if err != nil {
+				return err
+			}
+			v.Small = Small(x)
+		case "Count":
+			x, err := o.DecodeUInt16()
+			if err != nil {
+				return err
+			}
+			v.Count = Count(x)
+		case "Ratio":
+			x, err := o.DecodeFloat32()
+			if err != nil {
+				return err
+			}
+			v.Ratio = Ratio(x)
+		case "Char":
+			x, err := o.DecodeChar()
+			if err != nil {
+				return err
+			}
+			v.Char = ubjson.Char(x)
+		case "HighPrec":
+			x, err := o.DecodeHighPrecNumber()
+			if err != nil {
+				return err
+			}
+			v.HighPrec = ubjson.HighPrecNumber(x)
+		case "Number":
+			x, err := o.DecodeNumber()
+			if err != nil {
+				return err
+			}
+			v.Number = x
+		case "Raw":
+			x, err := o.DecodeRawValue()
+			if err != nil {
+				return err
+			}
+			v.Raw = x
+		case "Time":
+			x, err := o.DecodeTime()
+			if err != nil {
+				return err
+			}
+			v.Time = x
+		case "Nanos":
+			o.TimeFormat = ubjson.TimeNanos
+			x, err := o.DecodeTime()
+			if err != nil {
+				return err
+			}
+			v.Nanos = x
+			o.TimeFormat = tf
+		case "Duration":
+			o.TimeFormat = ubjson.TimeSeconds
+			x, err := o.DecodeDuration()
+			if err != nil {
+				return err
+			}
+			v.Duration = x
+			o.TimeFormat = tf
+		case "Bytes":
+			x, err := o.DecodeBytes([]uint8{})
+			if err != nil {
+				return err
+			}
+			v.Bytes = x
+		case "Int8s":
+			x, err := o.DecodeInt8s([]int8{})
+			if err != nil {
+				return err
+			}
+			v.Int8s = x
+		case "Int16s":
+			x, err := o.DecodeInt16s([]int16{})
+			if err != nil {
+				return err
+			}
+			v.Int16s = x
+		case "Int32s":
+			x, err := o.DecodeInt32s([]int32{})
+			if err != nil {
+				return err
+			}
+			v.Int32s = x
+		case "Int64s":
+			x, err := o.DecodeInt64s([]int64{})
+			if err != nil {
+				return err
+			}
+			v.Int64s = x
+		case "Float32s":
+			x, err := o.DecodeFloat32s([]float32{})
+			if err != nil {
+				return err
+			}
+			v.Float32s = x
+		case "Float64s":
+			x, err := o.DecodeFloat64s([]float64{})
+			if err != nil {
+				return err
+			}
+			v.Float64s = x
+		case "Named":
+			x, err := o.DecodeBytes([]uint8{})
+			if err != nil {
+				return err
+			}
+			v.Named = Bytes(x)
+		case "Strings":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > a.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(a.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)}
+				}
+				s := []string{}
+				if a.Len > 0 {
+					s = make([]string, 0, a.Len)
+				}
+				for a.NextElem() {
+					x, err := a.DecodeString()
+					if err != nil {
+						return err
+					}
+					s = append(s, x)
+				}
+				v.Strings = s
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "Names":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > a.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(a.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)}
+				}
+				s := Names{}
+				if a.Len > 0 {
+					s = make(Names, 0, a.Len)
+				}
+				for a.NextElem() {
+					x, err := a.DecodeString()
+					if err != nil {
+						return err
+					}
+					s = append(s, x)
+				}
+				v.Names = s
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "Smalls":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > a.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(a.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)}
+				}
+				s := []Small{}
+				if a.Len > 0 {
+					s = make([]Small, 0, a.Len)
+				}
+				for a.NextElem() {
+					x, err := a.DecodeInt8()
+					if err != nil {
+						return err
+					}
+					s = append(s, Small(x))
+				}
+				v.Smalls = s
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "Chars":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > a.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(a.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)}
+				}
+				s := []ubjson.Char{}
+				if a.Len > 0 {
+					s = make([]ubjson.Char, 0, a.Len)
+				}
+				for a.NextElem() {
+					x, err := a.DecodeChar()
+					if err != nil {
+						return err
+					}
+					s = append(s, ubjson.Char(x))
+				}
+				v.Chars = s
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "Uints":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > a.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(a.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)}
+				}
+				s := []uint{}
+				if a.Len > 0 {
+					s = make([]uint, 0, a.Len)
+				}
+				for a.NextElem() {
+					x, err := a.DecodeUInt()
+					if err != nil {
+						return err
+					}
+					s = append(s, x)
+				}
+				v.Uints = s
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "Array":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > 0 && a.Len != 3 {
+					return fmt.Errorf("unable to decode data length %d into array of length 3", a.Len)
+				}
+				for i := range v.Array {
+					x, err := a.DecodeInt16()
+					if err != nil {
+						return err
+					}
+					v.Array[i] = x
+				}
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "Times":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > a.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(a.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)}
+				}
+				s := []time.Time{}
+				if a.Len > 0 {
+					s = make([]time.Time, 0, a.Len)
+				}
+				for a.NextElem() {
+					x, err := a.DecodeTime()
+					if err != nil {
+						return err
+					}
+					s = append(s, x)
+				}
+				v.Times = s
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "Map":
+			if err := o.DecodeObject(func(m *ubjson.ObjectDecoder) error {
+				if m.Len > m.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(m.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", m.MaxCollectionAlloc, m.Len)}
+				}
+				var mp map[string]int32
+				if m.Len > 0 {
+					mp = make(map[string]int32, m.Len)
+				} else {
+					mp = make(map[string]int32)
+				}
+				for m.NextEntry() {
+					k, err := m.DecodeKey()
+					if err != nil {
+						return err
+					}
+					x, err := m.DecodeInt32()
+					if err != nil {
+						return err
+					}
+					mp[k] = x
+				}
+				v.Map = mp
+				return m.End()
+			}); err != nil {
+				return err
+			}
+		case "Labels":
+			if err := o.DecodeObject(func(m *ubjson.ObjectDecoder) error {
+				if m.Len > m.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(m.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", m.MaxCollectionAlloc, m.Len)}
+				}
+				var mp Labels
+				if m.Len > 0 {
+					mp = make(Labels, m.Len)
+				} else {
+					mp = make(Labels)
+				}
+				for m.NextEntry() {
+					k, err := m.DecodeKey()
+					if err != nil {
+						return err
+					}
+					x, err := m.DecodeUInt16()
+					if err != nil {
+						return err
+					}
+					mp[Name(k)] = Count(x)
+				}
+				v.Labels = mp
+				return m.End()
+			}); err != nil {
+				return err
+			}
+		case "renamed":
+			x, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			v.Renamed = x
+		case "Omitted":
+			x, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			v.Omitted = x
+		case "OmitSlice":
+			if err := o.DecodeArray(func(a *ubjson.ArrayDecoder) error {
+				if a.Len > a.MaxCollectionAlloc {
+					return &ubjson.LimitError{Limit: "MaxCollectionAlloc", Max: int64(a.MaxCollectionAlloc), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)}
+				}
+				s := []int{}
+				if a.Len > 0 {
+					s = make([]int, 0, a.Len)
+				}
+				for a.NextElem() {
+					x, err := a.DecodeInt()
+					if err != nil {
+						return err
+					}
+					s = append(s, x)
+				}
+				v.OmitSlice = s
+				return a.End()
+			}); err != nil {
+				return err
+			}
+		case "OmitPtr":
+			if err := o.Decode(&v.OmitPtr); err != nil {
+				return err
+			}
+		case "Quoted":
+			s, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			x, err := strconv.ParseInt(s, 10, 0)
+			if err != nil {
+				return err
+			}
+			v.Quoted = int(x)
+		case "QuotedF":
+			s, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			x, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return err
+			}
+			v.QuotedF = Ratio(x)
+		case "QuotedB":
+			s, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			x, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.QuotedB = x
+		case "QuotedP":
+			var s *string
+			if err := o.Decode(&s); err != nil {
+				return err
+			}
+			if s == nil {
+				v.QuotedP = nil
+			} else {
+				x, err := strconv.ParseInt(*s, 10, 0)
+				if err != nil {
+					return err
+				}
+				y := int(x)
+				v.QuotedP = &y
+			}
+		case "QuotedN":
+			var s *string
+			if err := o.Decode(&s); err != nil {
+				return err
+			}
+			if s == nil {
+				v.QuotedN = nil
+			} else {
+				x, err := strconv.ParseBool(*s)
+				if err != nil {
+					return err
+				}
+				y := x
+				v.QuotedN = &y
+			}
+		case "Inner":
+			if err := o.DecodeValue(&v.Inner); err != nil {
+				return err
+			}
+		case "InnerPtr":
+			if err := o.Decode(&v.InnerPtr); err != nil {
+				return err
+			}
+		case "Inners":
+			if err := o.Decode(&v.Inners); err != nil {
+				return err
+			}
+		case "Any":
+			if err := o.Decode(&v.Any); err != nil {
+				return err
+			}
+		case "Big":
+			if err := o.Decode(&v.Big); err != nil {
+				return err
+			}
+		case "Struct":
+			if err := o.Decode(&v.Struct); err != nil {
+				return err
+			}
+		case "Objects":
+			if err := o.Decode(&v.Objects); err != nil {
+				return err
+			}
+		case "E1":
+			x, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			v.Embedded.E1 = x
+		case "e2":
+			x, err := o.DecodeInt()
+			if err != nil {
+				return err
+			}
+			v.Embedded.E2 = x
+		case "P1":
+			if v.EmbeddedPtr == nil {
+				v.EmbeddedPtr = new(EmbeddedPtr)
+			}
+			x, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			v.EmbeddedPtr.P1 = x
+		case "P2":
+			if v.EmbeddedPtr == nil {
+				v.EmbeddedPtr = new(EmbeddedPtr)
+			}
+			x, err := o.DecodeInt()
+			if err != nil {
+				return err
+			}
+			v.EmbeddedPtr.P2 = x
+		default:
+			if o.DisallowUnknownFields {
+				return &ubjson.UnknownFieldError{Key: k, Type: reflect.TypeOf(v).Elem()}
+			}
+			if err := o.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return o.End()
+}
+
+// ubjsonFoldAll returns the name of the field of All which k matches
+// case-insensitively, if there is no exact match.
+func ubjsonFoldAll(k string) string {
+	switch k {
+	case "String",
+		"Bool",
+		"Int",
+		"Int8",
+		"Int16",
+		"Int32",
+		"Int64",
+		"Uint",
+		"Uint8",
+		"Uint16",
+		"Uint32",
+		"Uint64",
+		"Float32",
+		"Float64",
+		"Name",
+		"Flag",
+		"Small",
+		"Count",
+		"Ratio",
+		"Char",
+		"HighPrec",
+		"Number",
+		"Raw",
+		"Time",
+		"Nanos",
+		"Duration",
+		"Bytes",
+		"Int8s",
+		"Int16s",
+		"Int32s",
+		"Int64s",
+		"Float32s",
+		"Float64s",
+		"Named",
+		"Strings",
+		"Names",
+		"Smalls",
+		"Chars",
+		"Uints",
+		"Array",
+		"Times",
+		"Map",
+		"Labels",
+		"renamed",
+		"Omitted",
+		"OmitSlice",
+		"OmitPtr",
+		"Quoted",
+		"QuotedF",
+		"QuotedB",
+		"QuotedP",
+		"QuotedN",
+		"Inner",
+		"InnerPtr",
+		"Inners",
+		"Any",
+		"Big",
+		"Struct",
+		"Objects",
+		"E1",
+		"e2",
+		"P1",
+		"P2":
+		return k
+	}
+	for _, name := range [...]string{
+		"String",
+		"Bool",
+		"Int",
+		"Int8",
+		"Int16",
+		"Int32",
+		"Int64",
+		"Uint",
+		"Uint8",
+		"Uint16",
+		"Uint32",
+		"Uint64",
+		"Float32",
+		"Float64",
+		"Name",
+		"Flag",
+		"Small",
+		"Count",
+		"Ratio",
+		"Char",
+		"HighPrec",
+		"Number",
+		"Raw",
+		"Time",
+		"Nanos",
+		"Duration",
+		"Bytes",
+		"Int8s",
+		"Int16s",
+		"Int32s",
+		"Int64s",
+		"Float32s",
+		"Float64s",
+		"Named",
+		"Strings",
+		"Names",
+		"Smalls",
+		"Chars",
+		"Uints",
+		"Array",
+		"Times",
+		"Map",
+		"Labels",
+		"renamed",
+		"Omitted",
+		"OmitSlice",
+		"OmitPtr",
+		"Quoted",
+		"QuotedF",
+		"QuotedB",
+		"QuotedP",
+		"QuotedN",
+		"Inner",
+		"InnerPtr",
+		"Inners",
+		"Any",
+		"Big",
+		"Struct",
+		"Objects",
+		"E1",
+		"e2",
+		"P1",
+		"P2",
+	} {
+		if strings.EqualFold(k, name) {
+			return name
+		}
+	}
+	return k
+}
+
+// UBJSONType implements ubjson.Value.
+func (v *Inner) UBJSONType() ubjson.Marker {
+	return ubjson.ObjectStartMarker
+}
+
+// MarshalUBJSON implements ubjson.Value.
+func (v *Inner) MarshalUBJSON(e *ubjson.Encoder) error {
+	n := 1
+	if v.B != "" {
+		n++
+	}
+	o, err := e.ObjectLen(n)
+	if err != nil {
+		return err
+	}
+	if err := o.EncodeKey("a"); err != nil {
+		return err
+	}
+	if err := o.EncodeInt(v.A); err != nil {
+		return err
+	}
+	if v.B != "" {
+		if err := o.EncodeKey("b"); err != nil {
+			return err
+		}
+		if err := o.EncodeString(v.B); err != nil {
+			return err
+		}
+	}
+	return o.End()
+}
+
+// UnmarshalUBJSON implements ubjson.Value.
+func (v *Inner) UnmarshalUBJSON(d *ubjson.Decoder) error {
+	o, err := d.Object()
+	if err != nil {
+		return err
+	}
+	for o.NextEntry() {
+		k, err := o.DecodeKey()
+		if err != nil {
+			return err
+		}
+		if o.CaseInsensitiveFields {
+			k = ubjsonFoldInner(k)
+		}
+		switch k {
+		case "a":
+			x, err := o.DecodeInt()
+			if err != nil {
+				return err
+			}
+			v.A = x
+		case "b":
+			x, err := o.DecodeString()
+			if err != nil {
+				return err
+			}
+			v.B = x
+		default:
+			if o.DisallowUnknownFields {
+				return &ubjson.UnknownFieldError{Key: k, Type: reflect.TypeOf(v).Elem()}
+			}
+			if err := o.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return o.End()
+}
+
+// ubjsonFoldInner returns the name of the field of Inner which k matches
+// case-insensitively, if there is no exact match.
+func ubjsonFoldInner(k string) string {
+	switch k {
+	case "a", "b":
+		return k
+	}
+	for _, name := range [...]string{"a", "b"} {
+		if strings.EqualFold(k, name) {
+			return name
+		}
+	}
+	return k
+}
+
+// UBJSONType implements ubjson.Value.
+func (v *Empty) UBJSONType() ubjson.Marker {
+	return ubjson.ObjectStartMarker
+}
+
+// MarshalUBJSON implements ubjson.Value.
+func (v *Empty) MarshalUBJSON(e *ubjson.Encoder) error {
+	o, err := e.ObjectLen(0)
+	if err != nil {
+		return err
+	}
+	return o.End()
+}
+
+// UnmarshalUBJSON implements ubjson.Value.
+func (v *Empty) UnmarshalUBJSON(d *ubjson.Decoder) error {
+	o, err := d.Object()
+	if err != nil {
+		return err
+	}
+	for o.NextEntry() {
+		k, err := o.DecodeKey()
+		if err != nil {
+			return err
+		}
+		switch k {
+		default:
+			if o.DisallowUnknownFields {
+				return &ubjson.UnknownFieldError{Key: k, Type: reflect.TypeOf(v).Elem()}
+			}
+			if err := o.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return o.End()
+}
@@ -0,0 +1,120 @@
+// Package gentest declares types for testing the code generated by ubjsongen
+// against the reflective encoding of the ubjson package.
+package gentest
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/jmank88/ubjson"
+)
+
+//go:generate go run github.com/jmank88/ubjson/cmd/ubjsongen -type All,Inner,Empty -output all_ubjson.go
+
+type (
+	Name   string
+	Flag   bool
+	Small  int8
+	Count  uint16
+	Ratio  float32
+	Bytes  []byte
+	Names  []string
+	Labels map[Name]Count
+)
+
+// All has fields of each kind of generated code.
+type All struct {
+	String  string
+	Bool    bool
+	Int     int
+	Int8    int8
+	Int16   int16
+	Int32   int32
+	Int64   int64
+	Uint    uint
+	Uint8   uint8
+	Uint16  uint16
+	Uint32  uint32
+	Uint64  uint64
+	Float32 float32
+	Float64 float64
+
+	Name  Name
+	Flag  Flag
+	Small Small
+	Count Count
+	Ratio Ratio
+
+	Char     ubjson.Char
+	HighPrec ubjson.HighPrecNumber
+	Number   ubjson.Number
+	Raw      ubjson.RawValue
+
+	Time     time.Time
+	Nanos    time.Time     `ubjson:",nanos"`
+	Duration time.Duration `ubjson:",seconds"`
+
+	Bytes    []byte
+	Int8s    []int8
+	Int16s   []int16
+	Int32s   []int32
+	Int64s   []int64
+	Float32s []float32
+	Float64s []float64
+	Named    Bytes
+
+	Strings []string
+	Names   Names
+	Smalls  []Small
+	Chars   []ubjson.Char
+	Uints   []uint
+	Array   [3]int16
+	Times   []time.Time
+
+	Map    map[string]int32
+	Labels Labels
+
+	Renamed   string `ubjson:"renamed"`
+	Omitted   string `ubjson:",omitempty"`
+	OmitSlice []int  `ubjson:",omitempty"`
+	OmitPtr   *Inner `ubjson:",omitempty"`
+	Quoted    int    `ubjson:",string"`
+	QuotedF   Ratio  `ubjson:",string"`
+	QuotedB   bool   `ubjson:",string"`
+	QuotedP   *int   `ubjson:",string"`
+	QuotedN   *bool  `ubjson:",string"`
+	Skipped   string `ubjson:"-"`
+	private   string
+
+	Inner    Inner
+	InnerPtr *Inner
+	Inners   []Inner
+	Any      interface{}
+	Big      *big.Int
+	Struct   struct{ A, B int }
+	Objects  map[string][]int
+
+	Embedded
+	*EmbeddedPtr
+}
+
+// Inner is a generated type nested in All.
+type Inner struct {
+	A int    `ubjson:"a"`
+	B string `ubjson:"b,omitempty"`
+}
+
+// Empty has no fields.
+type Empty struct{}
+
+// Embedded is embedded in All.
+type Embedded struct {
+	E1 string
+	E2 int `ubjson:"e2"`
+}
+
+// EmbeddedPtr is embedded in All via a pointer.
+type EmbeddedPtr struct {
+	P1 string
+	P2 int `ubjson:",omitempty"`
+}
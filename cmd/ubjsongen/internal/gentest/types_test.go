@@ -0,0 +1,255 @@
+package gentest
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmank88/ubjson"
+)
+
+// reflectAll has the fields of All, without the generated methods, so it is
+// encoded via reflection.
+type reflectAll All
+
+func newAll(t *testing.T) All {
+	var number ubjson.Number
+	if err := ubjson.Unmarshal([]byte("I\x01\x00"), &number); err != nil {
+		t.Fatal(err)
+	}
+	quoted := 7
+	return All{
+		String:  "string",
+		Bool:    true,
+		Int:     -100000,
+		Int8:    -8,
+		Int16:   -300,
+		Int32:   -70000,
+		Int64:   -1 << 40,
+		Uint:    100000,
+		Uint8:   200,
+		Uint16:  300,
+		Uint32:  70000,
+		Uint64:  1 << 40,
+		Float32: 1.5,
+		Float64: -2.25,
+
+		Name:  "name",
+		Flag:  true,
+		Small: -1,
+		Count: 1000,
+		Ratio: 0.5,
+
+		Char:     'c',
+		HighPrec: "1.23456789012345678901234567890",
+		Number:   number,
+		Raw:      ubjson.RawValue("SU\x03raw"),
+
+		Time:     time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		Nanos:    time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC),
+		Duration: 90 * time.Second,
+
+		Bytes:    []byte("bytes"),
+		Int8s:    []int8{-1, 0, 1},
+		Int16s:   []int16{-300, 300},
+		Int32s:   []int32{-70000, 70000},
+		Int64s:   []int64{-1 << 40, 1 << 40},
+		Float32s: []float32{1.5, -1.5},
+		Float64s: []float64{2.25, -2.25},
+		Named:    Bytes("named"),
+
+		Strings: []string{"a", "b"},
+		Names:   Names{"c", "d"},
+		Smalls:  []Small{-2, 2},
+		Chars:   []ubjson.Char{'x', 'y'},
+		Uints:   []uint{1, 1000, 100000},
+		Array:   [3]int16{1, 2, 3},
+		Times:   []time.Time{time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)},
+
+		Map:    map[string]int32{"x": 1, "y": -1},
+		Labels: Labels{"a": 1, "b": 1000},
+
+		Renamed:   "renamed",
+		Omitted:   "omitted",
+		OmitSlice: []int{1},
+		OmitPtr:   &Inner{A: 1},
+		Quoted:    -42,
+		QuotedF:   0.25,
+		QuotedB:   true,
+		QuotedP:   &quoted,
+
+		Inner:    Inner{A: 1, B: "b"},
+		InnerPtr: &Inner{A: 2},
+		Inners:   []Inner{{A: 3}, {A: 4, B: "four"}},
+		Any:      "any",
+		Big:      big.NewInt(1 << 62),
+		Struct:   struct{ A, B int }{1, 2},
+		Objects:  map[string][]int{"a": {1, 2}, "b": {}},
+
+		Embedded:    Embedded{E1: "e1", E2: 2},
+		EmbeddedPtr: &EmbeddedPtr{P1: "p1", P2: 3},
+	}
+}
+
+// epoch is the Nanos time of otherwise empty values, since the zero time.Time
+// overflows TimeNanos.
+var epoch = time.Unix(0, 0).UTC()
+
+func TestAll_roundTrip(t *testing.T) {
+	for name, v := range map[string]All{
+		"zero": {Nanos: epoch},
+		"full": newAll(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			generated, err := ubjson.Marshal(&v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(generated, []byte("{#")) {
+				t.Errorf("expected sized object but got %q", generated)
+			}
+			reflective, err := ubjson.Marshal((*reflectAll)(&v))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Decode the output of each path with the other.
+			var fromGenerated reflectAll
+			if err := ubjson.Unmarshal(generated, &fromGenerated); err != nil {
+				t.Fatal(err)
+			}
+			var fromReflective All
+			if err := ubjson.Unmarshal(reflective, &fromReflective); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(All(fromGenerated), fromReflective) {
+				t.Errorf("decoded values differ:\nreflective: %#v\ngenerated:  %#v", All(fromGenerated), fromReflective)
+			}
+
+			var got All
+			if err := ubjson.Unmarshal(generated, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fromReflective, got) {
+				t.Errorf("expected %#v but got %#v", fromReflective, got)
+			}
+			if name == "full" && !reflect.DeepEqual(v, got) {
+				t.Errorf("expected %#v but got %#v", v, got)
+			}
+		})
+	}
+}
+
+func TestAll_roundTripBlock(t *testing.T) {
+	v := newAll(t)
+	b, err := ubjson.MarshalBlock(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got All
+	if err := ubjson.UnmarshalBlock(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, got) {
+		t.Errorf("expected %#v but got %#v", v, got)
+	}
+}
+
+func TestAll_omitEmpty(t *testing.T) {
+	v := All{Nanos: epoch, EmbeddedPtr: &EmbeddedPtr{}}
+	generated, err := ubjson.Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reflective, err := ubjson.Marshal((*reflectAll)(&v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g, r map[string]interface{}
+	if err := ubjson.Unmarshal(generated, &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := ubjson.Unmarshal(reflective, &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(g) != len(r) {
+		t.Errorf("expected %d keys but got %d", len(r), len(g))
+	}
+	for _, k := range []string{"Omitted", "OmitSlice", "OmitPtr", "P2", "Skipped", "private"} {
+		if _, ok := g[k]; ok {
+			t.Errorf("unexpected key %q", k)
+		}
+	}
+	if _, ok := g["P1"]; !ok {
+		t.Error("expected key \"P1\"")
+	}
+}
+
+func TestAll_typedContainers(t *testing.T) {
+	v := Inner{A: 1}
+	b, err := ubjson.Marshal(&struct {
+		Strings []string
+		Map     map[string]int32
+	}{[]string{"a"}, map[string]int32{"b": 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reflective map[string]ubjson.RawValue
+	if err := ubjson.Unmarshal(b, &reflective); err != nil {
+		t.Fatal(err)
+	}
+
+	all := All{Nanos: epoch, Strings: []string{"a"}, Map: map[string]int32{"b": 2}, Inner: v}
+	b, err = ubjson.Marshal(&all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generated map[string]ubjson.RawValue
+	if err := ubjson.Unmarshal(b, &generated); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"Strings", "Map"} {
+		if !bytes.Equal(reflective[k], generated[k]) {
+			t.Errorf("%s: expected %q but got %q", k, reflective[k], generated[k])
+		}
+	}
+	if exp := "[$S#U\x01U\x01a"; string(generated["Strings"]) != exp {
+		t.Errorf("expected %q but got %q", exp, generated["Strings"])
+	}
+}
+
+func TestInner_unknownFields(t *testing.T) {
+	b := []byte("{U\x01AU\x01U\x01cSU\x01cU\x01BSU\x01b}")
+
+	var v Inner
+	if err := ubjson.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if exp := (Inner{}); v != exp {
+		t.Errorf("expected %+v but got %+v", exp, v)
+	}
+
+	d := ubjson.NewDecoder(bytes.NewReader(b))
+	d.CaseInsensitiveFields = true
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if exp := (Inner{A: 1, B: "b"}); v != exp {
+		t.Errorf("expected %+v but got %+v", exp, v)
+	}
+
+	d = ubjson.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields = true
+	var uerr *ubjson.UnknownFieldError
+	if err := d.Decode(&v); err == nil {
+		t.Error("expected error for unknown field")
+	} else if !strings.Contains(err.Error(), `unknown field "A"`) {
+		t.Errorf("unexpected error: %v", err)
+	} else if !errors.As(err, &uerr) || uerr.Type != reflect.TypeOf(v) {
+		t.Errorf("expected *ubjson.UnknownFieldError for %T but got: %#v", v, err)
+	}
+}
@@ -0,0 +1,67 @@
+// Ubjsongen generates the ubjson.Value methods UBJSONType, MarshalUBJSON, and
+// UnmarshalUBJSON for struct types, using the type specific methods of
+// ubjson.Encoder and ubjson.Decoder in place of reflection.
+//
+// Usage:
+//
+//	ubjsongen -type T[,U...] [-output file] [dir]
+//
+// It is typically invoked via go:generate, from the package declaring the
+// types:
+//
+//	//go:generate ubjsongen -type T
+//
+// The generated methods encode the same fields as ubjson.Marshal, honoring
+// 'ubjson' struct tag names and the omitempty, string, text, nanos, and seconds
+// options, and they decode any object accepted by ubjson.Unmarshal, including
+// the DisallowUnknownFields and CaseInsensitiveFields options. Objects are
+// written with a count, and slices, arrays, and string keyed maps of scalar
+// values are written as optimized containers. Fields of other types fall back
+// to Encoder.Encode and Decoder.Decode.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: ubjsongen -type T[,U...] [-output file] [dir]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ubjsongen: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; required")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_ubjson.go")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+	outName := *output
+	if outName == "" {
+		outName = filepath.Join(dir, strings.ToLower(names[0])+"_ubjson.go")
+	}
+
+	src, err := generate(dir, names, outName, strings.Join(os.Args[1:], " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(outName, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,16 +1,28 @@
 package ubjson
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
+	"math/bits"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // MaxCollectionAlloc is the default maximum collection capacity allocation.
 // Can be overridden via Decoder.MaxCollectionAlloc.
 const MaxCollectionAlloc = 1 << 24
 
+// MaxDepth is the default maximum nesting depth of containers. Can be
+// overridden via Decoder.MaxDepth.
+const MaxDepth = 1000
+
 // Decoder provides methods for decoding UBJSON data types.
 type Decoder struct {
 	reader
@@ -18,40 +30,212 @@ type Decoder struct {
 	// Normally just reads the next marker, but strongly typed
 	// containers will do an internal check and also manage counters.
 	readValType func() (Marker, error)
+	// peekValType is called to get the next value's type marker without
+	// advancing. Normally just peeks at the next marker, but strongly typed
+	// containers return their type.
+	peekValType func() (Marker, error)
 	// Limits the capacity of allocated collections and returns errors rather
 	// than risking waste or panicking on unreasonable/malicious input.
 	// Example: "[[][$][T][#][l][999999999999999999]".
 	// New Decoders default to package const MaxCollectionAlloc.
 	MaxCollectionAlloc int
+	// Limits the nesting depth of containers, on every decoding path, and
+	// returns errors rather than exhausting the stack on malicious input.
+	// Example: "[[][[][[][[]...".
+	// New Decoders default to package const MaxDepth.
+	MaxDepth int
+	// Limits the total bytes read from the underlying io.Reader since the
+	// Decoder was created or Reset, and returns a *BytesLimitError rather than
+	// read any more. Zero means no limit. Input to Unmarshal is already in
+	// memory, so it is not limited.
+	MaxBytes int64
+	// Limits the total length of decoded strings (S, H), including object
+	// keys, since the Decoder was created or Reset, and returns a
+	// *StringBytesLimitError rather than allocate any more. Zero means no
+	// limit.
+	MaxStringBytes int64
+	// Limits the total number of array elements and object entries, including
+	// skipped ones, since the Decoder was created or Reset, and returns an
+	// *ElementsLimitError rather than decode any more. Zero means no limit.
+	MaxElements int64
+	// Requires numeric values to have the exact type marker of the target
+	// type. By default, any integer type (U,i,I,l,L) is accepted if the value
+	// fits, as are integer and narrower float types for float targets.
+	StrictNumbers bool
+	// Interpretation of integer time.Time and time.Duration values, as
+	// nanoseconds or seconds (TimeSeconds). Other representations are
+	// recognized by type marker.
+	TimeFormat TimeFormat
+	// Returns an *UnknownFieldError when an object key does not match any field
+	// of the target struct, rather than discarding the value.
+	DisallowUnknownFields bool
+	// Matches object keys to struct fields case-insensitively when there is
+	// no exact match, like encoding/json.
+	CaseInsensitiveFields bool
+	// Decodes numeric values (U,i,I,l,L,d,D,H) into interface{} as Numbers,
+	// rather than as the Go type corresponding to each type marker.
+	UseNumber bool
+
+	// Nesting depth of the container being decoded, or 0 at the top level.
+	depth int
+	// Number of Values being decoded directly by d, within which the input may
+	// not end cleanly.
+	inValue int
+	// Cumulative usage for MaxBytes, MaxStringBytes, and MaxElements, shared
+	// with container decoders.
+	usage *usage
+	// Decoder of the enclosing container, and the ArrayDecoder or
+	// ObjectDecoder embedding this one, or nil at the top level. Used to
+	// locate errors.
+	parent    *Decoder
+	container container
+	// Counts the bytes read by binary and block readers.
+	input *limitedReader
+	// Containers opened by Token.
+	tokens []tokenFrame
+	// Container decoders returned by Array and Object, which are reused for
+	// each container since only one may be open at a time.
+	array  *ArrayDecoder
+	object *ObjectDecoder
 }
 
-// NewDecoder returns a new Decoder.
+// NewDecoder returns a new Decoder. Decoding methods return io.EOF when r ends
+// cleanly before a top-level value, and an error wrapping io.ErrUnexpectedEOF
+// when it ends within one. Reads which return less than requested are
+// continued, so values may arrive in pieces, as from a network connection.
 func NewDecoder(r io.Reader) *Decoder {
-	d := &Decoder{reader: newBinaryReader(r), MaxCollectionAlloc: MaxCollectionAlloc}
-	d.readValType = d.readMarker
+	l := &limitedReader{r: r}
+	d := newDecoder(newBinaryReader(l))
+	d.input, l.d = l, d
 	return d
 }
 
+// newBytesDecoder returns a new Decoder which reads directly from b.
+func newBytesDecoder(b []byte, noCopy bool) *Decoder {
+	return newDecoder(newBytesReader(b, noCopy))
+}
+
 // NewBlockDecoder returns a new block-notation Decoder.
 func NewBlockDecoder(r io.Reader) *Decoder {
-	d := &Decoder{reader: newBlockReader(r), MaxCollectionAlloc: MaxCollectionAlloc}
-	d.readValType = d.readMarker
+	l := &limitedReader{r: r}
+	d := newDecoder(newBlockReader(l))
+	d.input, l.d = l, d
+	return d
+}
+
+// newDecoder returns a new Decoder reading from r, with default options.
+func newDecoder(r reader) *Decoder {
+	d := &Decoder{reader: r, MaxCollectionAlloc: MaxCollectionAlloc, MaxDepth: MaxDepth, usage: new(usage)}
+	d.readValType = d.readTopMarker
+	d.peekValType = d.peekTopMarker
 	return d
 }
 
+// readTopMarker reads the type marker of a top-level value, or returns
+// errEndOfInput if the input has ended cleanly before it.
+func (d *Decoder) readTopMarker() (Marker, error) {
+	if err := d.topEOF(); err != nil {
+		return 0, err
+	}
+	return d.readMarker()
+}
+
+// peekTopMarker is like readTopMarker, without advancing.
+func (d *Decoder) peekTopMarker() (Marker, error) {
+	if err := d.topEOF(); err != nil {
+		return 0, err
+	}
+	return d.peekMarker()
+}
+
+// topEOF returns errEndOfInput if the input has ended before a top-level value,
+// or io.ErrUnexpectedEOF if it has ended within a Value being decoded.
+func (d *Decoder) topEOF() error {
+	if !d.atEOF() {
+		return nil
+	}
+	if d.inValue > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return errEndOfInput
+}
+
+// atEOF returns true if the input has ended. Whitespace between blocks is
+// discarded. Other errors are left to be returned by the following read.
+func (d *Decoder) atEOF() bool {
+	switch r := d.reader.(type) {
+	case *bytesReader:
+		return r.off >= len(r.b)
+	case *binaryReader:
+		_, err := r.Peek(1)
+		return err == io.EOF
+	case *blockReader:
+		if r.next != "" {
+			return false
+		}
+		for {
+			b, err := r.Peek(1)
+			if err != nil {
+				return err == io.EOF
+			}
+			if b[0] != ' ' && b[0] != '\t' && b[0] != '\n' && b[0] != '\r' {
+				return false
+			}
+			r.Discard(1)
+		}
+	}
+	return false
+}
+
+// Reset discards any buffered input and state, and prepares d to read from r,
+// reusing its buffer when possible. Options are preserved, while the usage
+// counted toward MaxBytes, MaxStringBytes, and MaxElements starts over. Reset
+// must only be called on Decoders returned by NewDecoder or NewBlockDecoder.
+func (d *Decoder) Reset(r io.Reader) {
+	if d.input == nil {
+		d.input = &limitedReader{d: d}
+	}
+	d.input.r = r
+	switch dr := d.reader.(type) {
+	case *binaryReader:
+		dr.Reset(d.input)
+	case *blockReader:
+		dr.Reset(d.input)
+		dr.next = ""
+	default:
+		d.reader = newBinaryReader(d.input)
+	}
+	d.tokens = d.tokens[:0]
+	*d.usage = usage{}
+}
+
 // DecodeValue decodes the next value into v.
 func (d *Decoder) DecodeValue(v Value) error {
-	return d.decodeValue(v.UBJSONType(), v.UnmarshalUBJSON)
+	return d.decodeValue(v.UBJSONType(), reflect.TypeOf(v), v.UnmarshalUBJSON)
 }
 
-// decodeValue asserts a value's type marker, then decodes the data.
-func (d *Decoder) decodeValue(m Marker, decodeData func(*Decoder) error) error {
+// decodeValue asserts a value's type marker, then decodes the data. The Go
+// type t being decoded into is reported by errors, if not nil.
+func (d *Decoder) decodeValue(m Marker, t reflect.Type, decodeData func(*Decoder) error) error {
+	if err := d.expectValType(m, t); err != nil {
+		return d.locate(err)
+	}
+	d.inValue++
+	err := decodeData(d)
+	d.inValue--
+	return d.locate(err)
+}
+
+// expectValType reads the next value's type marker and returns an error if it
+// is not m. The Go type t being decoded into is reported by errors, if not
+// nil.
+func (d *Decoder) expectValType(m Marker, t reflect.Type) error {
 	if r, err := d.readValType(); err != nil {
 		return fmt.Errorf("failed trying to read type '%s': %w", m, err)
 	} else if r != m {
-		return errWrongTypeRead(m, r)
+		return errWrongTypeRead(m, r, t)
 	}
-	return decodeData(d)
+	return nil
 }
 
 // assertType reads the next marker and returns an error if it is not m.
@@ -61,7 +245,7 @@ func (d *Decoder) assertType(m Marker) error {
 		return fmt.Errorf("failed trying to read type '%s': %w", m, err)
 	}
 	if r != m {
-		return errWrongTypeRead(m, r)
+		return errWrongTypeRead(m, r, nil)
 	}
 	return nil
 }
@@ -70,7 +254,7 @@ func (d *Decoder) assertType(m Marker) error {
 func (d *Decoder) DecodeBool() (bool, error) {
 	m, err := d.readValType()
 	if err != nil {
-		return false, err
+		return false, d.locate(err)
 	}
 	switch m {
 	case TrueMarker:
@@ -78,110 +262,222 @@ func (d *Decoder) DecodeBool() (bool, error) {
 	case FalseMarker:
 		return false, nil
 	}
-	return false, errors.New("expected true or false marker")
+	return false, d.locate(&UnmarshalTypeError{Marker: m, Type: boolType, msg: "expected true or false marker"})
 }
 
-// DecodeUInt8 decodes a 'U' value into a uint8.
+// DecodeUInt8 decodes a 'U' value into a uint8. Unless StrictNumbers is set,
+// any integer value (U,i,I,l,L) within range is also accepted.
 func (d *Decoder) DecodeUInt8() (uint8, error) {
+	if !d.StrictNumbers {
+		u, err := d.decodeUInt(8)
+		return uint8(u), d.locate(err)
+	}
 	var v uint8
-	return v, d.decodeValue(UInt8Marker, func(*Decoder) error {
+	return v, d.decodeValue(UInt8Marker, uint8Type, func(*Decoder) error {
 		var err error
 		v, err = d.readUInt8()
 		return err
 	})
 }
 
-// DecodeInt8 decodes an 'i' value into an int8.
+// DecodeInt8 decodes an 'i' value into an int8. Unless StrictNumbers is set,
+// any integer value (U,i,I,l,L) within range is also accepted.
 func (d *Decoder) DecodeInt8() (int8, error) {
-	var v int8
-	return v, d.decodeValue(Int8Marker, func(*Decoder) error {
-		var err error
-		v, err = d.readInt8()
-		return err
-	})
+	i, err := d.decodeSigned(Int8Marker, 8)
+	return int8(i), d.locate(err)
 }
 
-// DecodeInt16 decodes an 'I' value into an int16.
+// DecodeInt16 decodes an 'I' value into an int16. Unless StrictNumbers is set,
+// any integer value (U,i,I,l,L) within range is also accepted.
 func (d *Decoder) DecodeInt16() (int16, error) {
-	var v int16
-	return v, d.decodeValue(Int16Marker, func(*Decoder) error {
-		var err error
-		v, err = d.readInt16()
-		return err
-	})
+	i, err := d.decodeSigned(Int16Marker, 16)
+	return int16(i), d.locate(err)
 }
 
-// DecodeInt32 decodes an 'l' value into an int32.
+// DecodeInt32 decodes an 'l' value into an int32. Unless StrictNumbers is set,
+// any integer value (U,i,I,l,L) within range is also accepted.
 func (d *Decoder) DecodeInt32() (int32, error) {
-	var v int32
-	return v, d.decodeValue(Int32Marker, func(*Decoder) error {
-		var err error
-		v, err = d.readInt32()
-		return err
-	})
+	i, err := d.decodeSigned(Int32Marker, 32)
+	return int32(i), d.locate(err)
 }
 
-// DecodeInt64 decodes an 'L' value into an int64.
+// DecodeInt64 decodes an 'L' value into an int64. Unless StrictNumbers is set,
+// any integer value (U,i,I,l,L) is also accepted.
 func (d *Decoder) DecodeInt64() (int64, error) {
-	var v int64
-	return v, d.decodeValue(Int64Marker, func(*Decoder) error {
-		var err error
-		v, err = d.readInt64()
-		return err
-	})
+	i, err := d.decodeSigned(Int64Marker, 64)
+	return i, d.locate(err)
 }
 
-// DecodeInt decodes an integer value (U,i,I,l,L) into an int.
+// decodeSigned decodes an integer value with type m, or if StrictNumbers is
+// not set, any integer value (U,i,I,l,L) which fits in a signed integer of
+// bitSize bits. A bitSize of 0 is the size of an int, which has no type marker
+// of its own: m is 0, and StrictNumbers accepts any integer type no wider than
+// an int.
+func (d *Decoder) decodeSigned(m Marker, bitSize int) (int64, error) {
+	size := bitSize
+	if size == 0 {
+		size = bits.UintSize
+	}
+	r, err := d.readValType()
+	if err != nil {
+		if m == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("failed trying to read type '%s': %w", m, err)
+	}
+	if r != m {
+		switch r {
+		case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+			if !d.StrictNumbers {
+				break
+			}
+			if m != 0 {
+				return 0, errWrongTypeRead(m, r, intOfSize(bitSize))
+			}
+			if n, _ := fixedSize(r); 8*n > size {
+				return 0, &UnmarshalTypeError{Marker: r, Type: intOfSize(bitSize), msg: fmt.Sprintf("type '%s' is wider than %s", r, intOfSize(bitSize))}
+			}
+		default:
+			if m == 0 {
+				return 0, &UnmarshalTypeError{Marker: r, Type: intOfSize(bitSize), msg: fmt.Sprintf("encountered non-int type marker: %s", r)}
+			}
+			return 0, errWrongTypeRead(m, r, intOfSize(bitSize))
+		}
+	}
+	i, err := readIntData(d, r)
+	if err != nil {
+		return 0, err
+	}
+	if size < 64 {
+		if max := int64(1)<<uint(size-1) - 1; i > max || i < -max-1 {
+			return 0, errOverflowRead(r, i, intOfSize(bitSize))
+		}
+	}
+	return i, nil
+}
+
+// DecodeInt decodes an integer value (U,i,I,l,L) within range into an int.
+// Ints are encoded in the smallest possible integer format, so StrictNumbers
+// only rejects types wider than an int, such as 'L' where an int is 32 bits.
 func (d *Decoder) DecodeInt() (int, error) {
+	i, err := d.decodeSigned(0, 0)
+	return int(i), d.locate(err)
+}
+
+// DecodeUInt decodes a non-negative integer value (U,i,I,l,L) or an integral
+// high precision number (H) into a uint.
+func (d *Decoder) DecodeUInt() (uint, error) {
+	u, err := d.decodeUInt(bits.UintSize)
+	return uint(u), d.locate(err)
+}
+
+// DecodeUInt16 decodes a non-negative integer value (U,i,I,l,L) or an integral
+// high precision number (H) into a uint16.
+func (d *Decoder) DecodeUInt16() (uint16, error) {
+	u, err := d.decodeUInt(16)
+	return uint16(u), d.locate(err)
+}
+
+// DecodeUInt32 decodes a non-negative integer value (U,i,I,l,L) or an integral
+// high precision number (H) into a uint32.
+func (d *Decoder) DecodeUInt32() (uint32, error) {
+	u, err := d.decodeUInt(32)
+	return uint32(u), d.locate(err)
+}
+
+// DecodeUInt64 decodes a non-negative integer value (U,i,I,l,L) or an integral
+// high precision number (H) into a uint64.
+func (d *Decoder) DecodeUInt64() (uint64, error) {
+	u, err := d.decodeUInt(64)
+	return u, d.locate(err)
+}
+
+// decodeUInt decodes a non-negative integer value (U,i,I,l,L) or an integral
+// high precision number (H), and returns an error if it does not fit in an
+// unsigned integer of bitSize bits.
+func (d *Decoder) decodeUInt(bitSize int) (uint64, error) {
 	m, err := d.readValType()
 	if err != nil {
 		return 0, err
 	}
+	var u uint64
 	switch m {
-	case UInt8Marker:
-		u, err := d.readUInt8()
-		return int(u), err
-	case Int8Marker:
-		i, err := d.readInt8()
-		return int(i), err
-	case Int16Marker:
-		i, err := d.readInt16()
-		return int(i), err
-	case Int32Marker:
-		i, err := d.readInt32()
-		return int(i), err
-	case Int64Marker:
-		i, err := d.readInt64()
-		return int(i), err
+	case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+		i, err := readIntData(d, m)
+		if err != nil {
+			return 0, err
+		}
+		if i < 0 {
+			return 0, &UnmarshalTypeError{Marker: m, Type: uintOfSize(bitSize), msg: fmt.Sprintf("unable to decode negative value %d into uint%d", i, bitSize)}
+		}
+		u = uint64(i)
+	case HighPrecNumMarker:
+		s, err := d.readString(d.MaxCollectionAlloc)
+		if err != nil {
+			return 0, err
+		}
+		u, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, &UnmarshalTypeError{Marker: m, Type: uintOfSize(bitSize), msg: fmt.Sprintf("unable to decode high precision number %q into uint%d: %v", s, bitSize, err)}
+		}
 	default:
-		return 0, fmt.Errorf("encountered non-int type marker: %s", m)
+		return 0, &UnmarshalTypeError{Marker: m, Type: uintOfSize(bitSize), msg: fmt.Sprintf("encountered non-int type marker: %s", m)}
 	}
+	if bitSize < 64 && u>>uint(bitSize) != 0 {
+		return 0, errOverflowRead(m, u, uintOfSize(bitSize))
+	}
+	return u, nil
 }
 
-// DecodeFloat32 decodes an 'f' value into a float32.
+// DecodeFloat32 decodes a 'd' value into a float32. Unless StrictNumbers is
+// set, integer values (U,i,I,l,L) are also accepted.
 func (d *Decoder) DecodeFloat32() (float32, error) {
-	var v float32
-	return v, d.decodeValue(Float32Marker, func(*Decoder) error {
-		var err error
-		v, err = d.readFloat32()
-		return err
-	})
+	f, err := d.decodeFloat(Float32Marker)
+	return float32(f), d.locate(err)
 }
 
-// DecodeFloat64 decodes an 'F' value into a float64.
+// DecodeFloat64 decodes a 'D' value into a float64. Unless StrictNumbers is
+// set, 'd' and integer values (U,i,I,l,L) are also accepted.
 func (d *Decoder) DecodeFloat64() (float64, error) {
-	var v float64
-	return v, d.decodeValue(Float64Marker, func(*Decoder) error {
-		var err error
-		v, err = d.readFloat64()
-		return err
-	})
+	f, err := d.decodeFloat(Float64Marker)
+	return f, d.locate(err)
+}
+
+// decodeFloat decodes a float value with type m, or if StrictNumbers is not
+// set, any integer value (U,i,I,l,L), or a 'd' value in place of a 'D'.
+func (d *Decoder) decodeFloat(m Marker) (float64, error) {
+	r, err := d.readValType()
+	if err != nil {
+		return 0, fmt.Errorf("failed trying to read type '%s': %w", m, err)
+	}
+	t := float64Type
+	if m == Float32Marker {
+		t = float32Type
+	}
+	if r != m && d.StrictNumbers {
+		return 0, errWrongTypeRead(m, r, t)
+	}
+	switch r {
+	case m:
+		if m == Float32Marker {
+			f, err := d.readFloat32()
+			return float64(f), err
+		}
+		return d.readFloat64()
+	case Float32Marker:
+		f, err := d.readFloat32()
+		return float64(f), err
+	case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+		i, err := readIntData(d, r)
+		return float64(i), err
+	}
+	return 0, errWrongTypeRead(m, r, t)
 }
 
 // DecodeHighPrecNumber decodes an 'H' value into a string.
 func (d *Decoder) DecodeHighPrecNumber() (string, error) {
 	var v string
-	return v, d.decodeValue(HighPrecNumMarker, func(*Decoder) error {
+	return v, d.decodeValue(HighPrecNumMarker, highPrecNumType, func(*Decoder) error {
 		var err error
 		v, err = d.readString(d.MaxCollectionAlloc)
 		return err
@@ -191,7 +487,7 @@ func (d *Decoder) DecodeHighPrecNumber() (string, error) {
 // DecodeChar decodes a 'C' value into a byte.
 func (d *Decoder) DecodeChar() (byte, error) {
 	var v byte
-	return v, d.decodeValue(CharMarker, func(*Decoder) error {
+	return v, d.decodeValue(CharMarker, charType, func(*Decoder) error {
 		var err error
 		v, err = d.readChar()
 		return err
@@ -201,7 +497,7 @@ func (d *Decoder) DecodeChar() (byte, error) {
 // DecodeString decodes an 'S' value into a string.
 func (d *Decoder) DecodeString() (string, error) {
 	var v string
-	return v, d.decodeValue(StringMarker, func(*Decoder) error {
+	return v, d.decodeValue(StringMarker, stringType, func(*Decoder) error {
 		var err error
 		v, err = d.readString(d.MaxCollectionAlloc)
 		return err
@@ -214,6 +510,33 @@ func (d *Decoder) decodeInterface() (interface{}, error) {
 		return nil, err
 	}
 	switch m {
+	case ArrayStartMarker:
+		a, err := d.Array()
+		if err != nil {
+			return nil, err
+		}
+		return arrayAsInterface(a)
+
+	case ObjectStartMarker:
+		o, err := d.Object()
+		if err != nil {
+			return nil, err
+		}
+		return objectAsInterface(o)
+	}
+	return d.readScalar(m)
+}
+
+// readScalar reads the data of a non-container value with type marker m.
+func (d *Decoder) readScalar(m Marker) (interface{}, error) {
+	if d.UseNumber {
+		switch m {
+		case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker,
+			Float32Marker, Float64Marker, HighPrecNumMarker:
+			return d.readNumber(m)
+		}
+	}
+	switch m {
 	case NullMarker, NoOpMarker:
 		return nil, nil
 
@@ -255,28 +578,121 @@ func (d *Decoder) decodeInterface() (interface{}, error) {
 		b, err := d.readChar()
 		return Char(b), err
 
-	case ArrayStartMarker:
-		a, err := d.Array()
+	default:
+		return nil, errSyntax("failed to decode: unrecgonized type marker %q", m)
+	}
+}
+
+// Skip discards the next value, including nested containers. The fixed size
+// elements of strongly typed containers are skipped in bulk, and binary
+// Decoders allocate nothing.
+func (d *Decoder) Skip() error {
+	m, err := d.readValType()
+	if err != nil {
+		return d.locate(err)
+	}
+	return d.locate(skipData(d.reader, m, d.containerDepth(), d))
+}
+
+// skipData skips the data of a value with type marker m, at container nesting
+// depth depth, within the limits of d.
+func skipData(r reader, m Marker, depth int, d *Decoder) error {
+	switch m {
+	case NoOpMarker:
+		return nil
+	case StringMarker, HighPrecNumMarker:
+		// Nothing is allocated, so the length is not limited.
+		l, err := readStringLen(r, math.MaxInt)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		return arrayAsInterface(a)
-
+		return r.skipStringData(l)
+	case ArrayStartMarker:
+		return skipContainer(r, false, depth, d)
 	case ObjectStartMarker:
-		o, err := d.Object()
-		if err != nil {
-			return nil, err
+		return skipContainer(r, true, depth, d)
+	}
+	if _, ok := fixedSize(m); ok {
+		return r.skipFixed(m, 1)
+	}
+	return errSyntax("failed to skip: unrecognized type marker %q", m)
+}
+
+// skipContainer skips the remainder of an array or object, following the
+// start marker.
+func skipContainer(r reader, object bool, depth int, d *Decoder) error {
+	if depth >= d.MaxDepth {
+		return errMaxDepth(d.MaxDepth)
+	}
+	m, l, err := readContainer(r)
+	if err != nil {
+		return err
+	}
+	if m == NoOpMarker {
+		return errSyntax("No-Op (N) is not a legal strong type")
+	}
+	if l < 0 {
+		end := arrayEndMarker
+		if object {
+			end = objectEndMarker
 		}
-		return objectAsInterface(o)
+		for {
+			next, err := r.peekMarker()
+			if err != nil {
+				return err
+			}
+			if next == end {
+				_, err := r.readMarker()
+				return err
+			}
+			if err := skipEntry(r, m, object, depth+1, d); err != nil {
+				return err
+			}
+		}
+	}
+	if !object {
+		if _, ok := fixedSize(m); ok {
+			if err := d.addElements(l); err != nil {
+				return err
+			}
+			return r.skipFixed(m, l)
+		}
+	}
+	for i := 0; i < l; i++ {
+		if err := skipEntry(r, m, object, depth+1, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	default:
-		return nil, fmt.Errorf("failed to decode: unrecgonized type marker %q", m)
+// skipEntry skips an array element or object entry, with type m if strongly
+// typed, at container nesting depth depth.
+func skipEntry(r reader, m Marker, object bool, depth int, d *Decoder) error {
+	if err := d.addElements(1); err != nil {
+		return err
 	}
+	if object {
+		l, err := readStringLen(r, math.MaxInt)
+		if err != nil {
+			return fmt.Errorf("failed to skip key: %w", err)
+		}
+		if err := r.skipStringData(l); err != nil {
+			return fmt.Errorf("failed to skip key: %w", err)
+		}
+	}
+	if m == 0 {
+		var err error
+		if m, err = r.readMarker(); err != nil {
+			return err
+		}
+	}
+	return skipData(r, m, depth, d)
 }
 
 // DecodeObject decodes an object container.
 func (d *Decoder) DecodeObject(decodeData func(*ObjectDecoder) error) error {
-	return d.decodeValue(ObjectStartMarker, func(d *Decoder) error {
+	return d.decodeValue(ObjectStartMarker, nil, func(d *Decoder) error {
 		o, err := d.Object()
 		if err != nil {
 			return err
@@ -286,28 +702,70 @@ func (d *Decoder) DecodeObject(decodeData func(*ObjectDecoder) error) error {
 }
 
 // Object begins decoding an object, and returns a specialized decoder for
-// object entries.
+// object entries. The ObjectDecoder is reused by d for subsequent objects, so
+// it must not be used after End.
 func (d *Decoder) Object() (*ObjectDecoder, error) {
+	if err := d.checkDepth(); err != nil {
+		return nil, d.locate(err)
+	}
 	m, l, err := readContainer(d)
 	if err != nil {
-		return nil, err
+		return nil, d.locate(err)
 	}
-	o := &ObjectDecoder{
-		Decoder: Decoder{
-			reader:             d.reader,
-			MaxCollectionAlloc: d.MaxCollectionAlloc,
-		},
-		ValType: m,
-		Len:     l,
+	o := d.object
+	if o == nil {
+		o = &ObjectDecoder{}
+		o.Decoder.readValType = o.readValType
+		o.Decoder.peekValType = o.peekValType
+		o.Decoder.container = o
+		d.object = o
 	}
-	o.Decoder.readValType = o.readValType
+	d.initChild(&o.Decoder)
+	o.ValType, o.Len, o.count, o.err = m, l, 0, nil
+	o.key, o.keyed = "", false
 
 	return o, nil
 }
 
+// initChild initializes c to decode the contents of a container, with the
+// same reader and options as d.
+func (d *Decoder) initChild(c *Decoder) {
+	c.reader = d.reader
+	c.parent = d
+	c.depth = d.containerDepth() + 1
+	c.MaxCollectionAlloc = d.MaxCollectionAlloc
+	c.MaxDepth = d.MaxDepth
+	c.MaxBytes = d.MaxBytes
+	c.MaxStringBytes = d.MaxStringBytes
+	c.MaxElements = d.MaxElements
+	c.usage = d.usage
+	c.StrictNumbers = d.StrictNumbers
+	c.TimeFormat = d.TimeFormat
+	c.DisallowUnknownFields = d.DisallowUnknownFields
+	c.CaseInsensitiveFields = d.CaseInsensitiveFields
+	c.UseNumber = d.UseNumber
+	// Token's wrappers of readValType and peekValType remain valid.
+	c.tokens = c.tokens[:0]
+}
+
+// containerDepth returns the nesting depth of the current container, including
+// those opened by Token.
+func (d *Decoder) containerDepth() int {
+	return d.depth + len(d.tokens)
+}
+
+// checkDepth returns an error if a container may not be opened without
+// exceeding MaxDepth.
+func (d *Decoder) checkDepth() error {
+	if d.containerDepth() >= d.MaxDepth {
+		return errMaxDepth(d.MaxDepth)
+	}
+	return nil
+}
+
 // DecodeArray decodes an array container.
 func (d *Decoder) DecodeArray(decodeData func(*ArrayDecoder) error) error {
-	return d.decodeValue(ArrayStartMarker, func(d *Decoder) error {
+	return d.decodeValue(ArrayStartMarker, nil, func(d *Decoder) error {
 		a, err := d.Array()
 		if err != nil {
 			return err
@@ -317,22 +775,27 @@ func (d *Decoder) DecodeArray(decodeData func(*ArrayDecoder) error) error {
 }
 
 // Array begins decoding an array, and returns a specialized decoder for array
-// elements.
+// elements. The ArrayDecoder is reused by d for subsequent arrays, so it must
+// not be used after End.
 func (d *Decoder) Array() (*ArrayDecoder, error) {
+	if err := d.checkDepth(); err != nil {
+		return nil, d.locate(err)
+	}
 	m, l, err := readContainer(d)
 	if err != nil {
-		return nil, err
+		return nil, d.locate(err)
 	}
 
-	a := &ArrayDecoder{
-		Decoder: Decoder{
-			reader:             d.reader,
-			MaxCollectionAlloc: d.MaxCollectionAlloc,
-		},
-		ElemType: m,
-		Len:      l,
+	a := d.array
+	if a == nil {
+		a = &ArrayDecoder{}
+		a.Decoder.readValType = a.readElemType
+		a.Decoder.peekValType = a.peekElemType
+		a.Decoder.container = a
+		d.array = a
 	}
-	a.Decoder.readValType = a.readElemType
+	d.initChild(&a.Decoder)
+	a.ElemType, a.Len, a.count, a.err = m, l, 0, nil
 
 	return a, nil
 }
@@ -350,6 +813,9 @@ type ObjectDecoder struct {
 	count int
 	// Deferred error to be returned by End().
 	err error
+	// Current key, if keyed, for locating errors.
+	key   string
+	keyed bool
 }
 
 // readValType increments and validates the count, and validate the type either
@@ -362,12 +828,24 @@ func (o *ObjectDecoder) readValType() (Marker, error) {
 	if o.count%2 != 0 {
 		return 0, errors.New("unable to decode value: expected key")
 	}
+	if err := o.addElements(1); err != nil {
+		return 0, err
+	}
 	if o.ValType == 0 {
 		return o.readMarker()
 	}
 	return o.ValType, nil
 }
 
+// peekValType returns the type either from the stream or from o.ValType,
+// without advancing.
+func (o *ObjectDecoder) peekValType() (Marker, error) {
+	if o.ValType == 0 {
+		return o.peekMarker()
+	}
+	return o.ValType, nil
+}
+
 // DecodeKey reads an object key.
 func (o *ObjectDecoder) DecodeKey() (string, error) {
 	o.count++
@@ -377,7 +855,18 @@ func (o *ObjectDecoder) DecodeKey() (string, error) {
 	if o.count%2 == 0 {
 		return "", errors.New("unable to decode key: expected value")
 	}
-	return o.readString(o.MaxCollectionAlloc)
+	o.keyed = false
+	k, err := o.readString(o.MaxCollectionAlloc)
+	if err != nil {
+		return "", o.locate(err)
+	}
+	o.key, o.keyed = k, true
+	return k, nil
+}
+
+// segment returns the key of the current entry, or false if none.
+func (o *ObjectDecoder) segment() (string, bool) {
+	return o.key, o.keyed
 }
 
 // NextEntry returns true if more entries are expected, or false if the end has
@@ -400,7 +889,7 @@ func (o *ObjectDecoder) NextEntry() bool {
 // from a length vs. count mismatch.
 func (o *ObjectDecoder) End() error {
 	if o.err != nil {
-		return o.err
+		return o.parent.locate(o.err)
 	}
 	if o.count%2 == 1 {
 		return errors.New("cannot end an object with a key")
@@ -408,10 +897,10 @@ func (o *ObjectDecoder) End() error {
 	if o.Len < 0 {
 		m, err := o.readMarker()
 		if err != nil {
-			return err
+			return o.parent.locate(err)
 		}
 		if m != objectEndMarker {
-			return errors.New("expected end marker")
+			return o.parent.locate(errSyntax("expected end marker"))
 		}
 	} else if 2*o.Len != o.count {
 		return errors.New("len count mismatch")
@@ -439,12 +928,32 @@ func (a *ArrayDecoder) readElemType() (Marker, error) {
 	if a.Len >= 0 && a.count > a.Len {
 		return 0, errTooMany(a.Len)
 	}
+	if err := a.addElements(1); err != nil {
+		return 0, err
+	}
 	if a.ElemType == 0 {
 		return a.readMarker()
 	}
 	return a.ElemType, nil
 }
 
+// segment returns the index of the current element, or false if none.
+func (a *ArrayDecoder) segment() (string, bool) {
+	if a.count == 0 {
+		return "", false
+	}
+	return strconv.Itoa(a.count - 1), true
+}
+
+// peekElemType returns the type either from the stream or from a.ElemType,
+// without advancing.
+func (a *ArrayDecoder) peekElemType() (Marker, error) {
+	if a.ElemType == 0 {
+		return a.peekMarker()
+	}
+	return a.ElemType, nil
+}
+
 // NextElem returns true when there is another element to decode, or false if
 // the end of the array has been reached or an error is encountered, in which
 // case it will be returned by the End method.
@@ -465,15 +974,15 @@ func (a *ArrayDecoder) NextElem() bool {
 // from a length vs. count mismatch.
 func (a *ArrayDecoder) End() error {
 	if a.err != nil {
-		return a.err
+		return a.parent.locate(a.err)
 	}
 	if a.Len < 0 {
 		m, err := a.readMarker()
 		if err != nil {
-			return err
+			return a.parent.locate(err)
 		}
 		if m != arrayEndMarker {
-			return errors.New("expected end marker")
+			return a.parent.locate(errSyntax("expected end marker"))
 		}
 	} else if a.Len != a.count {
 		return errors.New("len count mismatch")
@@ -483,7 +992,23 @@ func (a *ArrayDecoder) End() error {
 
 // Decode decodes a value into v by delegating to the appropriate type-specific
 // method. Recognizes the special types Char and HighPrecNumber to distinguish
-// from backing types.
+// from backing types, Number for any numeric value, and RawValue for any value
+// at all. Types implementing Value will be decoded via their UnmarshalUBJSON
+// method. Then time.Time and time.Duration are decoded via DecodeTime and
+// DecodeDuration, and big.Int, big.Float, and big.Rat via DecodeBigInt,
+// DecodeBigFloat, and DecodeBigRat. Otherwise, types implementing
+// encoding.TextUnmarshaler are decoded from strings (S), and then types
+// implementing encoding.BinaryUnmarshaler from arrays of uint8. Types
+// implementing both are decoded according to the next type marker.
+//
+// Errors describing the input, rather than the arguments, are located with
+// an Offset and a Path: a *SyntaxError for invalid input, an
+// *UnmarshalTypeError for values which do not fit the Go type, a *LimitError
+// for input exceeding the Decoder's limits, and an *UnsupportedTypeError for
+// Go types which cannot be decoded into. The same applies to the type
+// specific methods. If the input ends cleanly before the value, io.EOF is
+// returned, or if it ends within the value, a *SyntaxError wrapping
+// io.ErrUnexpectedEOF.
 func (d *Decoder) Decode(v interface{}) error {
 	if v == nil {
 		return errors.New("cannot decode into nil value")
@@ -491,264 +1016,716 @@ func (d *Decoder) Decode(v interface{}) error {
 	if val, ok := v.(Value); ok {
 		return d.DecodeValue(val)
 	}
-	switch t := v.(type) {
-	case *interface{}:
-		i, err := d.decodeInterface()
-		if err == nil {
-			*t = i
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr {
+		if isUnmarshaler(value.Type()) {
+			return d.locate(d.decodeUnmarshaler(v))
 		}
-		return err
+		return fmt.Errorf("can only decode into pointers, not: %s", value.Type())
+	}
+	if value.IsNil() {
+		return fmt.Errorf("cannot decode into nil pointer: %s", value.Type())
+	}
+	return d.locate(typeDecoder(value.Type().Elem())(d, value.Elem()))
+}
 
-	case *string:
-		s, err := d.DecodeString()
-		if err == nil {
-			*t = s
-		}
-		return err
+// A decoderFunc decodes into the addressable value v of a particular type.
+type decoderFunc func(d *Decoder, v reflect.Value) error
 
-	case *bool:
-		b, err := d.DecodeBool()
-		if err == nil {
-			*t = b
-		}
-		return err
+var decoderCache sync.Map // map[reflect.Type]decoderFunc
 
-	case *int:
-		i, err := d.DecodeInt()
-		if err == nil {
-			*t = i
-		}
-		return err
+// typeDecoder returns the cached decoderFunc for t, compiling it if necessary.
+// Based on 'encoding/json/encode.go'.
+func typeDecoder(t reflect.Type) decoderFunc {
+	if fi, ok := decoderCache.Load(t); ok {
+		return fi.(decoderFunc)
+	}
 
-	case *uint8:
-		u, err := d.DecodeUInt8()
-		if err == nil {
-			*t = u
-		}
-		return err
+	// To deal with recursive types, populate the map with an indirect func
+	// before we build it. This type waits on the real func (f) to be ready
+	// and then calls it. This indirect func is only used for recursive types.
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := decoderCache.LoadOrStore(t, decoderFunc(func(d *Decoder, v reflect.Value) error {
+		wg.Wait()
+		return f(d, v)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
+	}
 
-	case *int8:
-		i, err := d.DecodeInt8()
-		if err == nil {
-			*t = i
-		}
-		return err
+	// Compute the real decoder and replace the indirect func with it.
+	f = newTypeDecoder(t)
+	wg.Done()
+	decoderCache.Store(t, f)
+	return f
+}
 
-	case *int16:
-		i, err := d.DecodeInt16()
-		if err == nil {
-			*t = i
+// newTypeDecoder compiles a decoderFunc for t, following the precedence
+// documented by Decode.
+func newTypeDecoder(t reflect.Type) decoderFunc {
+	if reflect.PtrTo(t).Implements(valueType) {
+		return func(d *Decoder, v reflect.Value) error {
+			return d.DecodeValue(v.Addr().Interface().(Value))
 		}
-		return err
-
-	case *int32:
-		i, err := d.DecodeInt32()
-		if err == nil {
-			*t = i
+	}
+	switch t {
+	case timeType:
+		return func(d *Decoder, v reflect.Value) error {
+			tm, err := d.DecodeTime()
+			if err == nil {
+				*v.Addr().Interface().(*time.Time) = tm
+			}
+			return err
 		}
-		return err
-
-	case *int64:
-		i, err := d.DecodeInt64()
-		if err == nil {
-			*t = i
+	case durationType:
+		return func(d *Decoder, v reflect.Value) error {
+			dur, err := d.DecodeDuration()
+			if err == nil {
+				v.SetInt(int64(dur))
+			}
+			return err
 		}
-		return err
-
-	case *float32:
-		f, err := d.DecodeFloat32()
-		if err == nil {
-			*t = f
+	case bigIntType:
+		return func(d *Decoder, v reflect.Value) error {
+			i, err := d.DecodeBigInt()
+			if err == nil {
+				v.Addr().Interface().(*big.Int).Set(i)
+			}
+			return err
 		}
-		return err
+	case bigFloatType:
+		return func(d *Decoder, v reflect.Value) error {
+			t := v.Addr().Interface().(*big.Float)
+			h, err := d.decodeBigNumber(bigFloatType)
+			if err != nil {
+				return err
+			}
+			// Preserve the precision of the target, if set.
+			f, err := h.bigFloat(t.Prec())
+			if err == nil {
+				t.Set(f)
+			}
+			return err
+		}
+	case bigRatType:
+		return func(d *Decoder, v reflect.Value) error {
+			r, err := d.DecodeBigRat()
+			if err == nil {
+				v.Addr().Interface().(*big.Rat).Set(r)
+			}
+			return err
+		}
+	}
+	if isUnmarshaler(reflect.PtrTo(t)) {
+		return func(d *Decoder, v reflect.Value) error {
+			return d.decodeUnmarshaler(v.Addr().Interface())
+		}
+	}
+	switch t {
+	case charType:
+		return func(d *Decoder, v reflect.Value) error {
+			b, err := d.DecodeChar()
+			if err == nil {
+				v.SetUint(uint64(b))
+			}
+			return err
+		}
+	case highPrecNumType:
+		return func(d *Decoder, v reflect.Value) error {
+			s, err := d.DecodeHighPrecNumber()
+			if err == nil {
+				v.SetString(s)
+			}
+			return err
+		}
+	case numberType:
+		return func(d *Decoder, v reflect.Value) error {
+			n, err := d.DecodeNumber()
+			if err == nil {
+				*v.Addr().Interface().(*Number) = n
+			}
+			return err
+		}
+	case rawValueType:
+		return func(d *Decoder, v reflect.Value) error {
+			r, err := d.DecodeRawValue()
+			if err == nil {
+				v.SetBytes(r)
+			}
+			return err
+		}
+	}
 
-	case *float64:
-		f, err := d.DecodeFloat64()
-		if err == nil {
-			*t = f
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return decodeInterface
+		}
+	case reflect.String:
+		return func(d *Decoder, v reflect.Value) error {
+			s, err := d.DecodeString()
+			if err == nil {
+				v.SetString(s)
+			}
+			return err
+		}
+	case reflect.Bool:
+		return func(d *Decoder, v reflect.Value) error {
+			b, err := d.DecodeBool()
+			if err == nil {
+				v.SetBool(b)
+			}
+			return err
+		}
+	case reflect.Int:
+		return func(d *Decoder, v reflect.Value) error {
+			i, err := d.DecodeInt()
+			if err == nil {
+				v.SetInt(int64(i))
+			}
+			return err
+		}
+	case reflect.Int8:
+		return func(d *Decoder, v reflect.Value) error {
+			i, err := d.DecodeInt8()
+			if err == nil {
+				v.SetInt(int64(i))
+			}
+			return err
+		}
+	case reflect.Int16:
+		return func(d *Decoder, v reflect.Value) error {
+			i, err := d.DecodeInt16()
+			if err == nil {
+				v.SetInt(int64(i))
+			}
+			return err
+		}
+	case reflect.Int32:
+		return func(d *Decoder, v reflect.Value) error {
+			i, err := d.DecodeInt32()
+			if err == nil {
+				v.SetInt(int64(i))
+			}
+			return err
+		}
+	case reflect.Int64:
+		return func(d *Decoder, v reflect.Value) error {
+			i, err := d.DecodeInt64()
+			if err == nil {
+				v.SetInt(i)
+			}
+			return err
+		}
+	case reflect.Uint8:
+		return func(d *Decoder, v reflect.Value) error {
+			u, err := d.DecodeUInt8()
+			if err == nil {
+				v.SetUint(uint64(u))
+			}
+			return err
+		}
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bitSize := t.Bits()
+		return func(d *Decoder, v reflect.Value) error {
+			u, err := d.decodeUInt(bitSize)
+			if err == nil {
+				v.SetUint(u)
+			}
+			return err
+		}
+	case reflect.Float32:
+		return func(d *Decoder, v reflect.Value) error {
+			f, err := d.DecodeFloat32()
+			if err == nil {
+				v.SetFloat(float64(f))
+			}
+			return err
+		}
+	case reflect.Float64:
+		return func(d *Decoder, v reflect.Value) error {
+			f, err := d.DecodeFloat64()
+			if err == nil {
+				v.SetFloat(f)
+			}
+			return err
 		}
-		return err
 
-	case *Char:
-		b, err := d.DecodeChar()
-		if err == nil {
-			*t = Char(b)
+	case reflect.Ptr:
+		return newPtrDecoder(t)
+
+	// Containers
+	case reflect.Array:
+		return newArrayDecoder(t)
+	case reflect.Slice:
+		return newSliceDecoder(t)
+	case reflect.Map:
+		if kt := t.Key(); !decodableKeyType(kt) {
+			return func(*Decoder, reflect.Value) error {
+				return &UnsupportedTypeError{Type: t, msg: fmt.Sprintf("unable to decode map of type %s: key type must be a string, an integer, or implement encoding.TextUnmarshaler, but is %s", t, kt)}
+			}
 		}
-		return err
+		return newMapDecoder(t)
+	case reflect.Struct:
+		return newStructDecoder(t)
+	}
+
+	return func(*Decoder, reflect.Value) error {
+		return &UnsupportedTypeError{Type: t, msg: fmt.Sprintf("unable to decode this type of value: %s", reflect.PtrTo(t))}
+	}
+}
 
-	case *HighPrecNumber:
-		s, err := d.DecodeHighPrecNumber()
-		if err == nil {
-			*t = HighPrecNumber(s)
+var binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+// isUnmarshaler returns true if t implements encoding.TextUnmarshaler or
+// encoding.BinaryUnmarshaler.
+func isUnmarshaler(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || t.Implements(binaryUnmarshalerType)
+}
+
+// decodeUnmarshaler decodes into u, which implements
+// encoding.TextUnmarshaler, encoding.BinaryUnmarshaler, or both.
+func (d *Decoder) decodeUnmarshaler(u interface{}) error {
+	tu, isText := u.(encoding.TextUnmarshaler)
+	bu, isBinary := u.(encoding.BinaryUnmarshaler)
+	if isText && isBinary {
+		m, err := d.peekValType()
+		if err != nil {
+			return err
+		}
+		isText = m != ArrayStartMarker
+	}
+	if isText {
+		s, err := d.DecodeString()
+		if err != nil {
+			return err
 		}
+		if err := tu.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("failed to unmarshal text into %T: %w", u, err)
+		}
+		return nil
+	}
+	b, err := d.DecodeBytes(nil)
+	if err != nil {
 		return err
 	}
+	if err := bu.UnmarshalBinary(b); err != nil {
+		return fmt.Errorf("failed to unmarshal binary into %T: %w", u, err)
+	}
+	return nil
+}
 
-	value := reflect.ValueOf(v)
-	if value.Kind() != reflect.Ptr {
-		return fmt.Errorf("can only decode into pointers, not: %s", value.Type())
+// decodeInterface decodes into the empty interface v.
+func decodeInterface(d *Decoder, v reflect.Value) error {
+	i, err := d.decodeInterface()
+	if err != nil {
+		return err
 	}
-	// Containers
-	switch value.Elem().Kind() {
-	case reflect.Array:
-		return d.DecodeArray(arrayToArray(value))
-	case reflect.Slice:
-		return d.DecodeArray(arrayToSlice(value))
+	if i == nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		v.Set(reflect.ValueOf(i))
+	}
+	return nil
+}
 
-	case reflect.Map:
-		if value.Elem().Kind() != reflect.String &&
-			!value.Elem().Type().Key().ConvertibleTo(stringType) {
-			return fmt.Errorf("unable to decode map of type %s: key type must be string", value.Type())
+// newPtrDecoder returns a decoderFunc for the pointer type t, which decodes
+// into the target, allocating a new one if it is nil. A null (Z) value sets the
+// pointer to nil instead.
+func newPtrDecoder(t reflect.Type) decoderFunc {
+	elemDec := typeDecoder(t.Elem())
+	return func(d *Decoder, v reflect.Value) error {
+		m, err := d.peekValType()
+		if err != nil {
+			return err
 		}
-		return d.DecodeObject(objectIntoMap(value))
+		if m == NullMarker {
+			if _, err := d.readValType(); err != nil {
+				return err
+			}
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return elemDec(d, v.Elem())
+	}
+}
 
-	case reflect.Struct:
-		return d.DecodeObject(objectIntoStruct(value))
+// beginArray reads an array start marker, and begins decoding the array into
+// a value of type t.
+func (d *Decoder) beginArray(t reflect.Type) (*ArrayDecoder, error) {
+	if err := d.expectValType(ArrayStartMarker, t); err != nil {
+		return nil, err
 	}
+	return d.Array()
+}
 
-	return fmt.Errorf("unable to decode this type of value: %T %v", v, v)
+// beginObject reads an object start marker, and begins decoding the object
+// into a value of type t.
+func (d *Decoder) beginObject(t reflect.Type) (*ObjectDecoder, error) {
+	if err := d.expectValType(ObjectStartMarker, t); err != nil {
+		return nil, err
+	}
+	return d.Object()
 }
 
-// arrayToArray returns a function to decode an array container into
-// arrayPtr.Elem(). Returns an error if the lengths are not equal.
-func arrayToArray(arrayPtr reflect.Value) func(*ArrayDecoder) error {
-	return func(ad *ArrayDecoder) error {
-		arrayValue := arrayPtr.Elem()
-		elemType := arrayValue.Type().Elem()
-		if ad.Len > 0 {
-			if ad.Len >= 0 && ad.Len != arrayValue.Len() {
-				return fmt.Errorf("unable to decode data length %d into array of length %d", ad.Len, arrayValue.Len())
-			}
+// newArrayDecoder returns a decoderFunc for the array type t. Returns an error
+// if the lengths are not equal.
+func newArrayDecoder(t reflect.Type) decoderFunc {
+	elemDec := typeDecoder(t.Elem())
+	zero := reflect.Zero(t.Elem())
+	return func(d *Decoder, arrayValue reflect.Value) error {
+		ad, err := d.beginArray(t)
+		if err != nil {
+			return err
+		}
+		if ad.Len > 0 && ad.Len != arrayValue.Len() {
+			return &UnmarshalTypeError{Marker: ArrayStartMarker, Type: t, msg: fmt.Sprintf("unable to decode data length %d into array of length %d", ad.Len, arrayValue.Len())}
 		}
 
 		for i := 0; i < arrayValue.Len(); i++ {
-			elemPtr := reflect.New(elemType)
-			if err := ad.Decode(elemPtr.Interface()); err != nil {
-				return err
+			elemValue := arrayValue.Index(i)
+			elemValue.Set(zero)
+			if err := elemDec(&ad.Decoder, elemValue); err != nil {
+				return ad.locate(err)
 			}
-			arrayValue.Index(i).Set(elemPtr.Elem())
 		}
 		return ad.End()
 	}
 }
 
-// arrayToSlice returns a function to decode an array container into
-// slicePtr.Elem().
-func arrayToSlice(slicePtr reflect.Value) func(*ArrayDecoder) error {
-	return func(ad *ArrayDecoder) error {
-		sliceValue := slicePtr.Elem()
-		elemType := sliceValue.Type().Elem()
+// newSliceDecoder returns a decoderFunc for the slice type t.
+func newSliceDecoder(t reflect.Type) decoderFunc {
+	if _, bulk := bulkSliceType(t); bulk {
+		return func(d *Decoder, sliceValue reflect.Value) error {
+			ad, err := d.beginArray(t)
+			if err != nil {
+				return err
+			}
+			_, err = ad.readSlice(sliceValue)
+			return ad.locate(err)
+		}
+	}
+	elemDec := typeDecoder(t.Elem())
+	zero := reflect.Zero(t.Elem())
+	return func(d *Decoder, sliceValue reflect.Value) error {
+		ad, err := d.beginArray(t)
+		if err != nil {
+			return err
+		}
 		if ad.Len < 0 {
-			sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), 0, 0))
-			for ad.NextElem() {
-				elemPtr := reflect.New(elemType)
-				if err := ad.Decode(elemPtr.Interface()); err != nil {
-					return err
+			sliceValue.Set(reflect.MakeSlice(t, 0, 0))
+			for i := 0; ad.NextElem(); i++ {
+				sliceValue.Set(reflect.Append(sliceValue, zero))
+				if err := elemDec(&ad.Decoder, sliceValue.Index(i)); err != nil {
+					return ad.locate(err)
 				}
-				sliceValue = reflect.Append(sliceValue, elemPtr.Elem())
 			}
 		} else if ad.Len > ad.MaxCollectionAlloc {
-			return fmt.Errorf("collection exceeds max allocation limit of %d: %d", ad.MaxCollectionAlloc, ad.Len)
+			return errMaxAlloc(ad.MaxCollectionAlloc, ad.Len)
 		} else {
-			sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), ad.Len, ad.Len))
+			sliceValue.Set(reflect.MakeSlice(t, ad.Len, ad.Len))
 
 			for i := 0; i < ad.Len; i++ {
-				elemPtr := reflect.New(elemType)
-				if err := ad.Decode(elemPtr.Interface()); err != nil {
-					return err
+				if err := elemDec(&ad.Decoder, sliceValue.Index(i)); err != nil {
+					return ad.locate(err)
 				}
-				sliceValue.Index(i).Set(elemPtr.Elem())
 			}
 		}
 		return ad.End()
 	}
 }
 
-var zeroValue = reflect.Value{}
+// A structDecoder decodes structs of a particular type, with a decoderFunc for
+// each field.
+type structDecoder struct {
+	fields   fields
+	decoders []decoderFunc
+}
+
+func newStructDecoder(t reflect.Type) decoderFunc {
+	sd := &structDecoder{fields: cachedTypeFields(t)}
+	sd.decoders = make([]decoderFunc, len(sd.fields.list))
+	for i, f := range sd.fields.list {
+		sd.decoders[i] = typeDecoder(typeByIndex(t, f.index))
+	}
+	return sd.decode
+}
 
-func objectIntoStruct(structPtr reflect.Value) func(*ObjectDecoder) error {
-	return func(o *ObjectDecoder) error {
-		for o.NextEntry() {
-			k, err := o.DecodeKey()
+func (sd *structDecoder) decode(d *Decoder, structValue reflect.Value) error {
+	o, err := d.beginObject(structValue.Type())
+	if err != nil {
+		return err
+	}
+	for o.NextEntry() {
+		k, err := o.DecodeKey()
+		if err != nil {
+			return fmt.Errorf("failed to decode key with call #%d: %w", o.count, err)
+		}
+		i, ok := fieldIndex(&sd.fields, k, o.CaseInsensitiveFields)
+		if !ok {
+			if o.DisallowUnknownFields {
+				return d.locate(&UnknownFieldError{Key: k, Type: structValue.Type()})
+			}
+			// Discard value with no matching field.
+			if err := o.Skip(); err != nil {
+				return fmt.Errorf("failed to discard value for %q with call #%d: %w", k, o.count, o.locate(err))
+			}
+			continue
+		}
+		f := &sd.fields.list[i]
+		fv, err := fieldByIndexAlloc(structValue, f.index)
+		if err != nil {
+			return fmt.Errorf("failed to decode value for %q with call #%d: %w", k, o.count, err)
+		}
+		if f.asString {
+			if err := decodeScalarString(&o.Decoder, fv); err != nil {
+				return o.locate(err)
+			}
+		} else if f.hasTimeFormat {
+			tf := o.TimeFormat
+			o.TimeFormat = f.timeFormat
+			err := sd.decoders[i](&o.Decoder, fv)
+			o.TimeFormat = tf
 			if err != nil {
-				return fmt.Errorf("failed to decode key with call #%d: %w", o.count, err)
-			}
-			structValue := structPtr.Elem()
-			f := fieldByName(structValue, k)
-			if f == zeroValue {
-				// Discard value with no matching field.
-				// TODO could be more efficient with custom discardValue() method
-				if _, err := o.decodeInterface(); err != nil {
-					return fmt.Errorf("failed to discard value for %q with call #%d: %w", k, o.count, err)
-				}
-			} else if err := o.Decode(f.Addr().Interface()); err != nil {
-				return fmt.Errorf("failed to decode value for %q with call #%d: %w", k, o.count, err)
+				return o.locate(err)
 			}
+		} else if err := sd.decoders[i](&o.Decoder, fv); err != nil {
+			return o.locate(err)
 		}
-		return o.End()
 	}
+	return o.End()
 }
 
-// fieldByName looks up a field by name. Either the field name, or the overridden
-// 'ubjson' struct tag name.
-func fieldByName(structValue reflect.Value, k string) reflect.Value {
-	fs := cachedTypeFields(structValue.Type())
-	if i, ok := fs.indexByName[k]; ok {
-		return structValue.Field(i)
+// fieldIndex looks up a field by name. Either the field name, or the
+// overridden 'ubjson' struct tag name. If fold is true and there is no exact
+// match, the first field with a case-insensitively equal name is used.
+func fieldIndex(fs *fields, k string, fold bool) (int, bool) {
+	i, ok := fs.indexByName[k]
+	if !ok && fold {
+		for j := range fs.list {
+			if strings.EqualFold(fs.list[j].name, k) {
+				return j, true
+			}
+		}
 	}
-	return reflect.Value{}
+	return i, ok
 }
 
-func objectIntoMap(mapPtr reflect.Value) func(*ObjectDecoder) error {
-	return func(o *ObjectDecoder) error {
-		if o.Len > o.MaxCollectionAlloc {
-			return fmt.Errorf("collection exceeds max allocation limit of %d: %d", o.MaxCollectionAlloc, o.Len)
+// decodeScalarString decodes a string into a bool, integer, or float value v, or
+// a pointer to one, for fields with the 'string' tag option. A null sets a
+// pointer to nil.
+func decodeScalarString(d *Decoder, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		m, err := d.peekValType()
+		if err != nil {
+			return err
 		}
-		mapValue := mapPtr.Elem()
-		mapValue.Set(makeMap(mapValue.Type(), o.Len))
-		elemType := mapValue.Type().Elem()
-
-		for o.NextEntry() {
-			k, err := o.DecodeKey()
-			if err != nil {
-				return fmt.Errorf("failed to decode key #%d: %w", o.count, err)
+		if m == NullMarker {
+			if _, err := d.readValType(); err != nil {
+				return err
 			}
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	s, err := d.DecodeString()
+	if err != nil {
+		return err
+	}
+	return parseScalar(s, v)
+}
 
-			valPtr := reflect.New(elemType)
+// parseScalar parses s into a bool, integer, or float value v, for fields with
+// the 'string' tag option.
+func parseScalar(s string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unable to parse string into %s", v.Type())
+	}
+	return nil
+}
 
-			if err := o.Decode(valPtr.Interface()); err != nil {
-				return fmt.Errorf("failed to decode value #%d: %w", o.count, err)
-			}
+// A mapDecoder decodes maps of a particular type.
+type mapDecoder struct {
+	typ     reflect.Type
+	elemDec decoderFunc
+	// Whether keys are set directly as strings, rather than parsed.
+	stringKeys bool
+}
+
+func newMapDecoder(t reflect.Type) decoderFunc {
+	md := &mapDecoder{
+		typ:        t,
+		elemDec:    typeDecoder(t.Elem()),
+		stringKeys: t.Key().Kind() == reflect.String && !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType),
+	}
+	return md.decode
+}
+
+func (md *mapDecoder) decode(d *Decoder, mapValue reflect.Value) error {
+	o, err := d.beginObject(md.typ)
+	if err != nil {
+		return err
+	}
+	if o.Len > o.MaxCollectionAlloc {
+		return errMaxAlloc(o.MaxCollectionAlloc, o.Len)
+	}
+	mapValue.Set(makeMap(md.typ, o.Len))
+	keyType := md.typ.Key()
+	// Reused for each entry, since SetMapIndex copies.
+	keyValue := reflect.New(keyType).Elem()
+	elemValue := reflect.New(md.typ.Elem()).Elem()
+	zero := reflect.Zero(md.typ.Elem())
 
-			mapValue.SetMapIndex(reflect.ValueOf(k), valPtr.Elem())
+	for o.NextEntry() {
+		k, err := o.DecodeKey()
+		if err != nil {
+			return fmt.Errorf("failed to decode key #%d: %w", o.count, err)
+		}
+		if md.stringKeys {
+			keyValue.SetString(k)
+		} else if keyValue, err = parseMapKey(keyType, k); err != nil {
+			return fmt.Errorf("failed to parse key %q: %w", k, err)
 		}
-		return o.End()
+
+		elemValue.Set(zero)
+		if err := md.elemDec(&o.Decoder, elemValue); err != nil {
+			return o.locate(err)
+		}
+
+		mapValue.SetMapIndex(keyValue, elemValue)
 	}
+	return o.End()
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// decodableKeyType returns true if object keys can be decoded into map keys of
+// type kt.
+func decodableKeyType(kt reflect.Type) bool {
+	switch kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return reflect.PtrTo(kt).Implements(textUnmarshalerType)
+}
+
+// parseMapKey parses an object key into a map key of type kt. Types
+// implementing encoding.TextUnmarshaler are preferred, then strings are used
+// directly, and integers are parsed from decimal.
+// Based on 'encoding/json/decode.go'.
+func parseMapKey(kt reflect.Type, key string) (reflect.Value, error) {
+	if reflect.PtrTo(kt).Implements(textUnmarshalerType) {
+		kv := reflect.New(kt)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	}
+	kv := reflect.New(kt).Elem()
+	switch kt.Kind() {
+	case reflect.String:
+		kv.SetString(key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, kt.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		kv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, kt.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		kv.SetUint(n)
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported map key type %s", kt)
+	}
+	return kv, nil
 }
 
 // objectAsInterface reads an object and returns a map[string]T where T is
 // either interface{} or a stricter type if the object is strongly typed.
 func objectAsInterface(o *ObjectDecoder) (interface{}, error) {
 	if o.Len > o.MaxCollectionAlloc {
-		return nil, fmt.Errorf("collection exceeds max allocation limit of %d: %d", o.MaxCollectionAlloc, o.Len)
+		return nil, errMaxAlloc(o.MaxCollectionAlloc, o.Len)
 	}
 	if o.ValType == NoOpMarker {
-		return nil, errors.New("No-Op (N) is not a legal strong type")
+		return nil, errSyntax("No-Op (N) is not a legal strong type")
+	}
+	valType := elementTypeFor(o.ValType, o.UseNumber)
+	if valType == ifaceType {
+		n := o.Len
+		if n < 0 {
+			n = 0
+		}
+		m := make(map[string]interface{}, n)
+		for o.NextEntry() {
+			k, err := o.DecodeKey()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode key #%d: %w", o.count, err)
+			}
+			v, err := o.decodeInterface()
+			if err != nil {
+				return nil, o.locate(err)
+			}
+			m[k] = v
+		}
+		return m, o.End()
 	}
-	valType := elementTypeFor(o.ValType)
+
 	mapType := reflect.MapOf(stringType, valType)
 	mapValue := makeMap(mapType, o.Len)
+	valDec := typeDecoder(valType)
+	valValue := reflect.New(valType).Elem()
+	keyValue := reflect.New(stringType).Elem()
 	for o.NextEntry() {
 		k, err := o.DecodeKey()
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode key #%d: %w", o.count, err)
 		}
-		valPtr := reflect.New(mapType.Elem())
-		if err := o.Decode(valPtr.Interface()); err != nil {
-			return nil, fmt.Errorf("failed to decode value #%d: %w", o.count, err)
+		if err := valDec(&o.Decoder, valValue); err != nil {
+			return nil, o.locate(err)
 		}
-
-		mapValue.SetMapIndex(reflect.ValueOf(k), valPtr.Elem())
+		keyValue.SetString(k)
+		mapValue.SetMapIndex(keyValue, valValue)
 	}
 	return mapValue.Interface(), o.End()
 }
@@ -756,33 +1733,45 @@ func objectAsInterface(o *ObjectDecoder) (interface{}, error) {
 // arrayAsInterface reads an array container into a new slice []T, where T may
 // be strongly typed, or an interface{} in the general case.
 func arrayAsInterface(a *ArrayDecoder) (interface{}, error) {
-	var sliceValue reflect.Value
 	if a.ElemType == NoOpMarker {
-		return nil, errors.New("No-Op (N) is not a legal strong type")
+		return nil, errSyntax("No-Op (N) is not a legal strong type")
+	}
+	if a.Len > a.MaxCollectionAlloc {
+		return nil, errMaxAlloc(a.MaxCollectionAlloc, a.Len)
+	}
+	elemType := elementTypeFor(a.ElemType, a.UseNumber)
+	if elemType == ifaceType {
+		n := a.Len
+		if n < 0 {
+			n = 0
+		}
+		s := make([]interface{}, 0, n)
+		for a.NextElem() {
+			v, err := a.decodeInterface()
+			if err != nil {
+				return nil, a.locate(err)
+			}
+			s = append(s, v)
+		}
+		return s, a.End()
 	}
-	elemType := elementTypeFor(a.ElemType)
-	sliceType := reflect.SliceOf(elemType)
 
+	elemDec := typeDecoder(elemType)
+	sliceValue := reflect.New(reflect.SliceOf(elemType)).Elem()
 	if a.Len < 0 {
-		sliceValue = reflect.MakeSlice(sliceType, 0, 0)
-		for a.NextElem() {
-			elemPtr := reflect.New(elemType)
-			if err := a.Decode(elemPtr.Interface()); err != nil {
-				return nil, err
+		sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), 0, 0))
+		for i := 0; a.NextElem(); i++ {
+			sliceValue.Set(reflect.Append(sliceValue, reflect.Zero(elemType)))
+			if err := elemDec(&a.Decoder, sliceValue.Index(i)); err != nil {
+				return nil, a.locate(err)
 			}
-			sliceValue = reflect.Append(sliceValue, elemPtr.Elem())
 		}
-	} else if a.Len > a.MaxCollectionAlloc {
-		return "", fmt.Errorf("collection exceeds max allocation limit of %d: %d", a.MaxCollectionAlloc, a.Len)
 	} else {
-		sliceValue = reflect.MakeSlice(sliceType, a.Len, a.Len)
-
+		sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), a.Len, a.Len))
 		for i := 0; i < a.Len; i++ {
-			elemPtr := reflect.New(elemType)
-			if err := a.Decode(elemPtr.Interface()); err != nil {
-				return nil, err
+			if err := elemDec(&a.Decoder, sliceValue.Index(i)); err != nil {
+				return nil, a.locate(err)
 			}
-			sliceValue.Index(i).Set(elemPtr.Elem())
 		}
 	}
 
@@ -797,8 +1786,16 @@ var iface interface{}
 var ifaceType = reflect.TypeOf(&iface).Elem()
 
 // elementTypeFor returns the type into which data with this marker should be
-// decoded, falling back to interface{} in the general case.
-func elementTypeFor(m Marker) reflect.Type {
+// decoded, falling back to interface{} in the general case, and for numbers if
+// useNumber is set, so that they are decoded as Number.
+func elementTypeFor(m Marker, useNumber bool) reflect.Type {
+	if useNumber {
+		switch m {
+		case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker,
+			Float32Marker, Float64Marker, HighPrecNumMarker:
+			return ifaceType
+		}
+	}
 	switch m {
 	case TrueMarker, FalseMarker:
 		return boolType
@@ -829,6 +1826,11 @@ func elementTypeFor(m Marker) reflect.Type {
 var (
 	boolType        = reflect.TypeOf(true)
 	uint8Type       = reflect.TypeOf(uint8(0))
+	uint16Type      = reflect.TypeOf(uint16(0))
+	uint32Type      = reflect.TypeOf(uint32(0))
+	uint64Type      = reflect.TypeOf(uint64(0))
+	uintType        = reflect.TypeOf(uint(0))
+	intType         = reflect.TypeOf(0)
 	int8Type        = reflect.TypeOf(int8(0))
 	int16Type       = reflect.TypeOf(int16(0))
 	int32Type       = reflect.TypeOf(int32(0))
@@ -838,4 +1840,41 @@ var (
 	stringType      = reflect.TypeOf("")
 	charType        = reflect.TypeOf(Char(0))
 	highPrecNumType = reflect.TypeOf(HighPrecNumber(""))
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	bigIntType      = reflect.TypeOf(big.Int{})
+	bigFloatType    = reflect.TypeOf(big.Float{})
+	bigRatType      = reflect.TypeOf(big.Rat{})
+	numberType      = reflect.TypeOf(Number{})
+	rawValueType    = reflect.TypeOf(RawValue(nil))
 )
+
+// intOfSize returns the signed integer type of bitSize, or int for 0.
+func intOfSize(bitSize int) reflect.Type {
+	switch bitSize {
+	case 8:
+		return int8Type
+	case 16:
+		return int16Type
+	case 32:
+		return int32Type
+	case 64:
+		return int64Type
+	}
+	return intType
+}
+
+// uintOfSize returns the unsigned integer type of bitSize, or uint for 0.
+func uintOfSize(bitSize int) reflect.Type {
+	switch bitSize {
+	case 8:
+		return uint8Type
+	case 16:
+		return uint16Type
+	case 32:
+		return uint32Type
+	case 64:
+		return uint64Type
+	}
+	return uintType
+}
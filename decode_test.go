@@ -1,9 +1,17 @@
 package ubjson
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestUnmarshal(t *testing.T) {
@@ -26,6 +34,80 @@ func (tc *testCase) unmarshal(t *testing.T) {
 	}
 }
 
+func TestDecoder_Decode(t *testing.T) {
+	t.Parallel()
+	for name, tc := range cases {
+		t.Run(name, tc.decode)
+	}
+}
+
+// decode is like unmarshal, but decodes from an io.Reader.
+func (tc *testCase) decode(t *testing.T) {
+	var expected interface{} = tc.value
+	actual := reflect.New(reflect.ValueOf(tc.value).Type())
+
+	if err := NewDecoder(bytes.NewReader(tc.binary)).Decode(actual.Interface()); err != nil {
+		t.Fatalf("failed to decode: %+v\n", err)
+	}
+	if !reflect.DeepEqual(actual.Elem().Interface(), expected) {
+		t.Errorf("\nexpected: %T %v \nbut got:  %T %v",
+			expected, expected, actual.Elem().Interface(), actual.Elem().Interface())
+	}
+}
+
+func TestUnmarshalNoCopy(t *testing.T) {
+	t.Parallel()
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			actual := reflect.New(reflect.ValueOf(tc.value).Type())
+			bin := append([]byte{}, tc.binary...)
+			if err := UnmarshalNoCopy(bin, actual.Interface()); err != nil {
+				t.Fatalf("failed to unmarshal: %+v\n", err)
+			}
+			if !reflect.DeepEqual(actual.Elem().Interface(), tc.value) {
+				t.Errorf("\nexpected: %T %v \nbut got:  %T %v",
+					tc.value, tc.value, actual.Elem().Interface(), actual.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestUnmarshalNoCopy_alias(t *testing.T) {
+	bin := []byte{'{',
+		'U', 1, 'S', 'S', 'U', 2, 'h', 'i',
+		'U', 1, 'B', '[', '$', 'U', '#', 'U', 2, 1, 2,
+		'}'}
+	var v struct {
+		S string
+		B []byte
+	}
+	if err := Unmarshal(bin, &v); err != nil {
+		t.Fatal(err)
+	}
+	var alias struct {
+		S string
+		B []byte
+	}
+	if err := UnmarshalNoCopy(bin, &alias); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, alias) {
+		t.Fatalf("expected %v but got %v", v, alias)
+	}
+
+	bin[7], bin[18] = 'H', 9
+	if v.S != "hi" || v.B[0] != 1 {
+		t.Errorf("expected Unmarshal to copy but got %v", v)
+	}
+	if alias.S != "Hi" || alias.B[0] != 9 {
+		t.Errorf("expected UnmarshalNoCopy to alias but got %v", alias)
+	}
+	if cap(alias.B) != 2 {
+		t.Errorf("expected aliased byte array capacity to be limited but got %d", cap(alias.B))
+	}
+}
+
 func TestUnmarshalBlock(t *testing.T) {
 	t.Parallel()
 	for name, tc := range cases {
@@ -69,6 +151,53 @@ func TestUnmarshalDiscardUnknownFields(t *testing.T) {
 	}
 }
 
+func TestUnmarshalSkipTaggedField(t *testing.T) {
+	type val struct {
+		A int8 `ubjson:"-"`
+		B int8
+	}
+
+	exp := val{B: 5}
+	var got val
+
+	bin := []byte{'{', 'U', 0x01, 'A', 'i', 0x08, 'U', 0x01, 'B', 'i', 0x05, '}'}
+
+	if err := Unmarshal(bin, &got); err != nil {
+		t.Fatal(err)
+	} else if got != exp {
+		t.Errorf("\nexpected: %T %v \nbut got:  %T %v", exp, exp, got, got)
+	}
+}
+
+func TestUnmarshalPointers(t *testing.T) {
+	i := int8(1)
+	got := pointerStruct{I: &i, N: new(string)}
+
+	bin := []byte{'{', 'U', 0x01, 'I', 'i', 0x05, 'U', 0x01, 'N', 'Z', '}'}
+
+	if err := Unmarshal(bin, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.I != &i || i != 5 {
+		t.Errorf("expected existing pointer to be reused and set to 5, but got %p %v", got.I, *got.I)
+	}
+	if got.N != nil {
+		t.Errorf("expected nil but got %q", *got.N)
+	}
+
+	var pp **int8
+	if err := Unmarshal([]byte{'i', 0x07}, &pp); err != nil {
+		t.Fatal(err)
+	} else if pp == nil || *pp == nil || **pp != 7 {
+		t.Errorf("expected pointer to pointer to 7")
+	}
+	if err := Unmarshal([]byte{'Z'}, &pp); err != nil {
+		t.Fatal(err)
+	} else if pp != nil {
+		t.Errorf("expected nil but got %v", pp)
+	}
+}
+
 func TestFuzzUnmarshalCrashers(t *testing.T) {
 	for _, data := range []string{
 		"[$F#i\x8a\x98b\x82ϟ6/\x9b\"\xe4\x88\xe8\xf0\xe0\f1A",
@@ -149,3 +278,967 @@ func TestFuzzUnmarshalBlock_strong_type_NoOp_object(t *testing.T) {
 		t.Errorf("expected failure but got: %v", i)
 	}
 }
+
+func TestUnmarshalUIntRange(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		binary []byte
+		into   interface{}
+	}{
+		{"negative", []byte{'i', 0xFF}, new(uint32)},
+		{"uint16-overflow", []byte{'l', 0x00, 0x01, 0x00, 0x00}, new(uint16)},
+		{"uint32-overflow", []byte{0: 'L', 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, new(uint32)},
+		{"uint64-overflow", append([]byte{'H', 0x55, 0x14}, "18446744073709551616"...), new(uint64)},
+		{"uint64-fraction", append([]byte{'H', 0x55, 0x03}, "1.5"...), new(uint64)},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Unmarshal(tt.binary, tt.into); err == nil {
+				t.Errorf("expected error but got: %v", reflect.ValueOf(tt.into).Elem())
+			}
+		})
+	}
+}
+
+func TestUnmarshalNumericWidening(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		binary []byte
+		exp    interface{}
+	}{
+		{"U-int64", []byte{'U', 0xFF}, int64(255)},
+		{"l-int64", []byte{'l', 0xFF, 0xFF, 0xFF, 0xFE}, int64(-2)},
+		{"i-int16", []byte{'i', 0x80}, int16(-128)},
+		{"I-int8", []byte{'I', 0x00, 0x7F}, int8(127)},
+		{"L-int32", []byte{0: 'L', 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, int32(256)},
+		{"i-uint8", []byte{'i', 0x7F}, uint8(127)},
+		{"U-float32", []byte{'U', 0x02}, float32(2)},
+		{"I-float64", []byte{'I', 0x01, 0x00}, float64(256)},
+		{"d-float64", []byte{'d', 0x3F, 0xC0, 0x00, 0x00}, float64(1.5)},
+		{"array-U-int64", []byte{'[', '$', 'U', '#', 'U', 0x02, 0x01, 0x02}, []int64{1, 2}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := reflect.New(reflect.TypeOf(tt.exp))
+			if err := Unmarshal(tt.binary, got.Interface()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), tt.exp) {
+				t.Errorf("expected %T %v but got %T %v", tt.exp, tt.exp, got.Elem().Interface(), got.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestUnmarshalNumericWideningErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		binary []byte
+		into   interface{}
+	}{
+		{"int8-overflow", []byte{'I', 0x01, 0x00}, new(int8)},
+		{"int16-underflow", []byte{'l', 0xFF, 0xFF, 0x00, 0x00}, new(int16)},
+		{"int32-overflow", []byte{0: 'L', 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, new(int32)},
+		{"uint8-negative", []byte{'i', 0xFF}, new(uint8)},
+		{"float-into-int", []byte{'d', 0x3F, 0xC0, 0x00, 0x00}, new(int64)},
+		{"float64-into-float32", []byte{0: 'D', 0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, new(float32)},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Unmarshal(tt.binary, tt.into); err == nil {
+				t.Errorf("expected error but got: %v", reflect.ValueOf(tt.into).Elem())
+			}
+		})
+	}
+}
+
+func TestDecoder_StrictNumbers(t *testing.T) {
+	d := NewDecoder(bytes.NewReader([]byte{'U', 0x05}))
+	d.StrictNumbers = true
+	if _, err := d.DecodeInt64(); err == nil {
+		t.Error("expected error decoding 'U' into int64")
+	}
+
+	d = NewDecoder(bytes.NewReader([]byte{'[', '$', 'U', '#', 'U', 0x01, 0x05}))
+	d.StrictNumbers = true
+	var f []float64
+	if err := d.Decode(&f); err == nil {
+		t.Error("expected error decoding 'U' element into float64")
+	}
+
+	// Ints accept any integer type no wider than an int.
+	d = NewDecoder(bytes.NewReader([]byte{'U', 0x05, 'l', 0, 0, 0, 0x06, 'D', 0, 0, 0, 0, 0, 0, 0, 0}))
+	d.StrictNumbers = true
+	for _, exp := range []int{5, 6} {
+		if i, err := d.DecodeInt(); err != nil {
+			t.Fatal(err)
+		} else if i != exp {
+			t.Errorf("expected %d but got %d", exp, i)
+		}
+	}
+	var uerr *UnmarshalTypeError
+	if _, err := d.DecodeInt(); !errors.As(err, &uerr) || uerr.Type != reflect.TypeOf(0) {
+		t.Errorf("expected *UnmarshalTypeError for int but got: %v", err)
+	}
+	d = NewDecoder(bytes.NewReader([]byte{'L', 0, 0, 0, 0, 0, 0, 0, 0x07}))
+	d.StrictNumbers = true
+	if _, err := d.decodeSigned(0, 32); !errors.As(err, &uerr) || uerr.Marker != Int64Marker {
+		t.Errorf("expected *UnmarshalTypeError for 'L' into a 32 bit int but got: %v", err)
+	}
+}
+
+func TestDecoder_DecodeInt_overflow(t *testing.T) {
+	b := []byte{'L', 0, 0, 0x01, 0, 0, 0, 0, 0}
+	i, err := NewDecoder(bytes.NewReader(b)).DecodeInt()
+	if bits.UintSize == 64 {
+		if err != nil || int64(i) != 1<<40 {
+			t.Errorf("expected %d but got %d: %v", int64(1<<40), i, err)
+		}
+	} else if err == nil {
+		t.Errorf("expected overflow error but got %d", i)
+	}
+	// As if an int were 32 bits.
+	var uerr *UnmarshalTypeError
+	if _, err := NewDecoder(bytes.NewReader(b)).decodeSigned(0, 32); !errors.As(err, &uerr) {
+		t.Errorf("expected *UnmarshalTypeError for overflow but got: %v", err)
+	}
+}
+
+func TestUnmarshalMapKeyErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		binary []byte
+		into   interface{}
+	}{
+		{"int8-overflow", []byte{'{', '#', 'U', 0x01, 'U', 0x03, '3', '0', '0', 'U', 0x01}, new(map[int8]uint8)},
+		{"uint-negative", []byte{'{', '#', 'U', 0x01, 'U', 0x02, '-', '1', 'U', 0x01}, new(map[uint]uint8)},
+		{"unsupported", []byte{'{', '#', 'U', 0x01, 'U', 0x01, '1', 'U', 0x01}, new(map[float64]uint8)},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Unmarshal(tt.binary, tt.into); err == nil {
+				t.Errorf("expected error but got: %v", reflect.ValueOf(tt.into).Elem())
+			}
+		})
+	}
+}
+
+// A textBinary implements both encoding.TextUnmarshaler and
+// encoding.BinaryUnmarshaler.
+type textBinary string
+
+func (t *textBinary) UnmarshalText(text []byte) error {
+	*t = textBinary("text:" + string(text))
+	return nil
+}
+
+func (t *textBinary) UnmarshalBinary(data []byte) error {
+	*t = textBinary("binary:" + string(data))
+	return nil
+}
+
+func TestUnmarshalTextOrBinary(t *testing.T) {
+	var got textBinary
+	if err := Unmarshal([]byte{'S', 'U', 0x01, 'a'}, &got); err != nil {
+		t.Fatal(err)
+	} else if got != "text:a" {
+		t.Errorf("expected text:a but got %s", got)
+	}
+	if err := Unmarshal([]byte{'[', '$', 'U', '#', 'U', 0x01, 'b'}, &got); err != nil {
+		t.Fatal(err)
+	} else if got != "binary:b" {
+		t.Errorf("expected binary:b but got %s", got)
+	}
+}
+
+func TestDecoder_DisallowUnknownFields(t *testing.T) {
+	type config struct {
+		Name  string
+		Inner struct{ Port int }
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(map[string]interface{}{
+		"Name":  "x",
+		"Inner": map[string]interface{}{"Prot": 8080},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	var c config
+	if err := Unmarshal(b, &c); err != nil {
+		t.Fatalf("expected unknown field to be discarded by default: %s", err)
+	}
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields = true
+	err := d.Decode(&c)
+	if err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `"Prot"`) || !strings.Contains(msg, "struct { Port int }") {
+		t.Errorf("expected error to name key and type: %s", msg)
+	}
+	var uerr *UnknownFieldError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("expected *UnknownFieldError but got: %#v", err)
+	}
+	if uerr.Key != "Prot" || uerr.Type != reflect.TypeOf(c.Inner) {
+		t.Errorf("unexpected key %q and type %s", uerr.Key, uerr.Type)
+	}
+	if uerr.Path != "/Inner" {
+		t.Errorf("expected path /Inner but got %q", uerr.Path)
+	}
+	if exp := int64(bytes.Index(b, []byte("Prot")) + 4); uerr.Offset != exp {
+		t.Errorf("expected offset %d but got %d", exp, uerr.Offset)
+	}
+
+	// Truncated input ending after the unknown key, or within it.
+	end := bytes.Index(b, []byte("Prot")) + 4
+	for _, truncated := range [][]byte{b[:end], b[:end-1]} {
+		d := NewDecoder(bytes.NewReader(truncated))
+		d.DisallowUnknownFields = true
+		err := d.Decode(&c)
+		if err == io.EOF {
+			t.Fatalf("%d bytes: expected a located error but got io.EOF", len(truncated))
+		}
+		var serr *SyntaxError
+		if len(truncated) == end {
+			if !errors.As(err, &uerr) || uerr.Path != "/Inner" || uerr.Offset != int64(end) {
+				t.Errorf("%d bytes: expected *UnknownFieldError at /Inner but got: %v", len(truncated), err)
+			}
+		} else if !errors.As(err, &serr) || !errors.Is(err, io.ErrUnexpectedEOF) || serr.Path != "/Inner" {
+			t.Errorf("%d bytes: expected *SyntaxError at /Inner but got: %v", len(truncated), err)
+		}
+	}
+}
+
+func TestDecoder_CaseInsensitiveFields(t *testing.T) {
+	type config struct {
+		Name   string
+		Port   int `ubjson:"port"`
+		PORT   int
+		Tagged int `ubjson:"tagged"`
+	}
+	b, err := Marshal(map[string]interface{}{
+		"NAME":   "x",
+		"Port":   2,
+		"TAGGED": 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c config
+	if err := Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if exp := (config{}); c != exp {
+		t.Errorf("expected exact matching %+v but got %+v", exp, c)
+	}
+
+	c = config{}
+	d := NewDecoder(bytes.NewReader(b))
+	d.CaseInsensitiveFields = true
+	if err := d.Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	// "Port" folds to the first of the case-insensitively equal fields.
+	if exp := (config{Name: "x", Port: 2, Tagged: 3}); c != exp {
+		t.Errorf("expected %+v but got %+v", exp, c)
+	}
+}
+
+func TestDecoder_Skip(t *testing.T) {
+	t.Parallel()
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			bin := append(append([]byte{}, tc.binary...), 'U', 42)
+			d := NewDecoder(bytes.NewReader(bin))
+			if err := d.Skip(); err != nil {
+				t.Fatal(err)
+			}
+			if u, err := d.DecodeUInt8(); err != nil {
+				t.Fatal(err)
+			} else if u != 42 {
+				t.Errorf("expected 42 after skipped value but got %d", u)
+			}
+
+			block := tc.block + "[U][42]"
+			d = NewBlockDecoder(strings.NewReader(block))
+			if err := d.Skip(); err != nil {
+				t.Fatal(err)
+			}
+			if u, err := d.DecodeUInt8(); err != nil {
+				t.Fatal(err)
+			} else if u != 42 {
+				t.Errorf("expected 42 after skipped value but got %d", u)
+			}
+		})
+	}
+}
+
+func TestDecoder_Skip_allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	bin := []byte{'{',
+		'U', 1, 'a', '[', '$', 'D', '#', 'U', 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		'U', 1, 'b', '[', 'S', 'U', 2, 'h', 'i', '{', '#', 'U', 1, 'U', 1, 'c', 'T', ']',
+		'U', 1, 'd', '{', '$', 'I', '#', 'U', 1, 'U', 1, 'e', 0, 1,
+		'}'}
+	r := bytes.NewReader(bin)
+	d := NewDecoder(r)
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(bin)
+		if err := d.Skip(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations but got %f", allocs)
+	}
+}
+
+type nestedSlice []nestedSlice
+
+func TestDecoder_MaxDepth(t *testing.T) {
+	var decodeArrays func(d *Decoder) error
+	decodeArrays = func(d *Decoder) error {
+		return d.DecodeArray(func(a *ArrayDecoder) error {
+			for a.NextElem() {
+				if err := decodeArrays(&a.Decoder); err != nil {
+					return err
+				}
+			}
+			return a.End()
+		})
+	}
+	for name, decode := range map[string]func(d *Decoder) error{
+		"interface": func(d *Decoder) error {
+			var v interface{}
+			return d.Decode(&v)
+		},
+		"reflect": func(d *Decoder) error {
+			var v nestedSlice
+			return d.Decode(&v)
+		},
+		"typed": decodeArrays,
+		"skip": func(d *Decoder) error {
+			return d.Skip()
+		},
+		"raw": func(d *Decoder) error {
+			_, err := d.DecodeRawValue()
+			return err
+		},
+		"token": func(d *Decoder) error {
+			for {
+				if _, err := d.Token(); err != nil {
+					return err
+				}
+				if len(d.tokens) == 0 {
+					return nil
+				}
+			}
+		},
+	} {
+		decode := decode
+		t.Run(name, func(t *testing.T) {
+			// Beyond the default limit, without end markers.
+			d := NewDecoder(bytes.NewReader(bytes.Repeat([]byte{'['}, MaxDepth+1)))
+			var lerr *LimitError
+			if err := decode(d); !errors.As(err, &lerr) {
+				t.Errorf("expected *LimitError but got: %v", err)
+			} else if lerr.Limit != "MaxDepth" || lerr.Max != MaxDepth {
+				t.Errorf("expected MaxDepth of %d but got %s of %d", MaxDepth, lerr.Limit, lerr.Max)
+			}
+
+			d = NewDecoder(bytes.NewReader([]byte("[[[]]]")))
+			d.MaxDepth = 3
+			if err := decode(d); err != nil {
+				t.Errorf("unexpected error within limit: %v", err)
+			}
+
+			d = NewDecoder(bytes.NewReader([]byte("[[[[]]]]")))
+			d.MaxDepth = 3
+			if err := decode(d); err == nil {
+				t.Error("expected error")
+			} else if exp := "exceeded max nesting depth of 3"; !strings.Contains(err.Error(), exp) {
+				t.Errorf("expected error containing %q but got: %v", exp, err)
+			}
+		})
+	}
+}
+
+type nestedObject struct {
+	A *nestedObject `ubjson:"a"`
+}
+
+type nestedMap map[string]nestedMap
+
+// TestDecoder_deepObjects checks that errors from deeply nested objects are
+// not rewrapped at each level, which would take quadratic time and memory.
+func TestDecoder_deepObjects(t *testing.T) {
+	const depth = 10000
+	b := bytes.Repeat([]byte("{U\x01a"), depth)
+	for name, v := range map[string]interface{}{
+		"interface": new(interface{}),
+		"map":       new(nestedMap),
+		"struct":    new(nestedObject),
+	} {
+		d := NewDecoder(bytes.NewReader(b))
+		d.MaxDepth = depth + 1
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		err := d.Decode(v)
+		runtime.ReadMemStats(&after)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s: expected *SyntaxError but got: %v", name, err)
+		} else if exp := strings.Repeat("/a", depth); serr.Path != exp {
+			t.Errorf("%s: expected path of length %d but got %d", name, len(exp), len(serr.Path))
+		}
+		if len(err.Error()) > 100 {
+			t.Errorf("%s: error message of length %d grows with depth", name, len(err.Error()))
+		}
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 64<<20 {
+			t.Errorf("%s: allocated %d bytes", name, alloc)
+		}
+	}
+}
+
+func TestDecoder_MaxBytes(t *testing.T) {
+	// Three values of 6 bytes each.
+	bin := bytes.Repeat([]byte("SU\x03abc"), 3)
+	block := []byte(strings.Repeat("[S][U][3][abc]", 3))
+	for name, d := range map[string]*Decoder{
+		"binary": NewDecoder(bytes.NewReader(bin)),
+		"block":  NewBlockDecoder(bytes.NewReader(block)),
+	} {
+		d.MaxBytes = 12
+		if name == "block" {
+			d.MaxBytes = 28
+		}
+		for i := 0; i < 2; i++ {
+			if s, err := d.DecodeString(); err != nil {
+				t.Fatalf("%s: unexpected error within limit: %v", name, err)
+			} else if s != "abc" {
+				t.Errorf("%s: expected \"abc\" but got %q", name, s)
+			}
+		}
+		var lerr *BytesLimitError
+		if _, err := d.DecodeString(); !errors.As(err, &lerr) {
+			t.Errorf("%s: expected *BytesLimitError but got: %v", name, err)
+		} else if lerr.Limit != d.MaxBytes {
+			t.Errorf("%s: expected limit %d but got %d", name, d.MaxBytes, lerr.Limit)
+		}
+	}
+
+	// Within a single value.
+	d := NewDecoder(bytes.NewReader(append(append([]byte{'['}, bytes.Repeat([]byte{'Z'}, 100)...), ']')))
+	d.MaxBytes = 50
+	var v interface{}
+	var lerr *BytesLimitError
+	if err := d.Decode(&v); !errors.As(err, &lerr) {
+		t.Errorf("expected *BytesLimitError but got: %v", err)
+	}
+
+	// Reset starts over.
+	d.Reset(bytes.NewReader(bin))
+	d.MaxBytes = 6
+	if _, err := d.DecodeString(); err != nil {
+		t.Errorf("unexpected error after reset: %v", err)
+	}
+}
+
+func TestDecoder_MaxStringBytes(t *testing.T) {
+	for name, decode := range map[string]func(d *Decoder) error{
+		"interface": func(d *Decoder) error {
+			var v interface{}
+			return d.Decode(&v)
+		},
+		"strings": func(d *Decoder) error {
+			var v []string
+			return d.Decode(&v)
+		},
+		"raw": func(d *Decoder) error {
+			_, err := d.DecodeRawValue()
+			return err
+		},
+		"raw elements": func(d *Decoder) error {
+			var v []RawValue
+			return d.Decode(&v)
+		},
+		"token": func(d *Decoder) error {
+			for {
+				if _, err := d.Token(); err != nil {
+					return err
+				}
+				if len(d.tokens) == 0 {
+					return nil
+				}
+			}
+		},
+	} {
+		decode := decode
+		t.Run(name, func(t *testing.T) {
+			bin := []byte("[SU\x03abcSU\x03def]")
+			d := NewDecoder(bytes.NewReader(bin))
+			d.MaxStringBytes = 6
+			if err := decode(d); err != nil {
+				t.Errorf("unexpected error within limit: %v", err)
+			}
+
+			d = NewDecoder(bytes.NewReader(bin))
+			d.MaxStringBytes = 5
+			var lerr *StringBytesLimitError
+			if err := decode(d); !errors.As(err, &lerr) {
+				t.Errorf("expected *StringBytesLimitError but got: %v", err)
+			} else if lerr.Limit != 5 {
+				t.Errorf("expected limit 5 but got %d", lerr.Limit)
+			}
+		})
+	}
+
+	// Object keys count too.
+	d := NewDecoder(bytes.NewReader([]byte("{U\x03abcSU\x03def}")))
+	d.MaxStringBytes = 5
+	var v map[string]string
+	var lerr *StringBytesLimitError
+	if err := d.Decode(&v); !errors.As(err, &lerr) {
+		t.Errorf("expected *StringBytesLimitError but got: %v", err)
+	}
+}
+
+func TestDecoder_MaxElements(t *testing.T) {
+	for name, decode := range map[string]func(d *Decoder) error{
+		"interface": func(d *Decoder) error {
+			var v interface{}
+			return d.Decode(&v)
+		},
+		"reflect": func(d *Decoder) error {
+			var v [][]int8
+			return d.Decode(&v)
+		},
+		"skip": func(d *Decoder) error {
+			return d.Skip()
+		},
+		"raw": func(d *Decoder) error {
+			_, err := d.DecodeRawValue()
+			return err
+		},
+		"token": func(d *Decoder) error {
+			for {
+				if _, err := d.Token(); err != nil {
+					return err
+				}
+				if len(d.tokens) == 0 {
+					return nil
+				}
+			}
+		},
+	} {
+		decode := decode
+		t.Run(name, func(t *testing.T) {
+			// 2 arrays, with 3 elements in bulk and 2 individually.
+			bin := []byte("[[$i#U\x03\x01\x02\x03[i\x04i\x05]]")
+			d := NewDecoder(bytes.NewReader(bin))
+			d.MaxElements = 7
+			if err := decode(d); err != nil {
+				t.Errorf("unexpected error within limit: %v", err)
+			}
+
+			d = NewDecoder(bytes.NewReader(bin))
+			d.MaxElements = 6
+			var lerr *ElementsLimitError
+			if err := decode(d); !errors.As(err, &lerr) {
+				t.Errorf("expected *ElementsLimitError but got: %v", err)
+			} else if lerr.Limit != 6 {
+				t.Errorf("expected limit 6 but got %d", lerr.Limit)
+			}
+		})
+	}
+}
+
+func TestDecoder_errorLocation(t *testing.T) {
+	item := "{U\x04nameSU\x01a}"
+	items := []byte("{U\x05items[" + strings.Repeat(item, 3) + "{U\x04nameU\x05}]}")
+	type doc struct {
+		Items []struct {
+			Name string `ubjson:"name"`
+		} `ubjson:"items"`
+	}
+	for name, decode := range map[string]func([]byte) error{
+		"unmarshal": func(b []byte) error {
+			var v doc
+			return Unmarshal(b, &v)
+		},
+		"stream": func(b []byte) error {
+			var v doc
+			return NewDecoder(bytes.NewReader(b)).Decode(&v)
+		},
+	} {
+		var terr *UnmarshalTypeError
+		if err := decode(items); !errors.As(err, &terr) {
+			t.Errorf("%s: expected *UnmarshalTypeError but got: %v", name, err)
+			continue
+		}
+		if terr.Path != "/items/3/name" {
+			t.Errorf("%s: expected path \"/items/3/name\" but got %q", name, terr.Path)
+		}
+		if exp := int64(len(items) - 4); terr.Offset != exp {
+			t.Errorf("%s: expected offset %d but got %d", name, exp, terr.Offset)
+		}
+		if terr.Marker != UInt8Marker || terr.Type != stringType {
+			t.Errorf("%s: expected 'U' into string but got %q into %s", name, terr.Marker, terr.Type)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		b      []byte
+		v      interface{}
+		path   string
+		offset int64
+		target interface{}
+	}{
+		"syntax": {
+			b: []byte("{U\x01a[U\x01X]}"), v: new(interface{}),
+			path: "/a/1", offset: 8, target: new(*SyntaxError),
+		},
+		"eof": {
+			b: []byte("[#U\x03U\x01U\x02"), v: new([]int),
+			path: "/2", offset: 8, target: new(*SyntaxError),
+		},
+		"limit": {
+			b: []byte("{U\x01a[[]]}"), v: new(interface{}),
+			path: "/a/0", offset: 6, target: new(*LimitError),
+		},
+		"unsupported": {
+			b: []byte("{U\x02~/U\x01}"), v: new(struct {
+				C chan int `ubjson:"~/"`
+			}),
+			path: "/~0~1", offset: 5, target: new(*UnsupportedTypeError),
+		},
+	} {
+		d := NewDecoder(bytes.NewReader(tc.b))
+		d.MaxDepth = 2
+		err := d.Decode(tc.v)
+		if !errors.As(err, tc.target) {
+			t.Errorf("%s: expected %T but got: %v", name, tc.target, err)
+			continue
+		}
+		var path string
+		var offset int64
+		switch e := reflect.ValueOf(tc.target).Elem().Interface().(type) {
+		case *SyntaxError:
+			path, offset = e.Path, e.Offset
+		case *LimitError:
+			path, offset = e.Path, e.Offset
+		case *UnsupportedTypeError:
+			path, offset = e.Path, e.Offset
+		}
+		if path != tc.path || offset != tc.offset {
+			t.Errorf("%s: expected %q at %d but got %q at %d", name, tc.path, tc.offset, path, offset)
+		}
+	}
+
+	// Containers opened by Token.
+	d := NewDecoder(bytes.NewReader([]byte("{U\x01a[U\x01X]}")))
+	var err error
+	for err == nil {
+		_, err = d.Token()
+	}
+	var serr *SyntaxError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected *SyntaxError but got: %v", err)
+	}
+	if serr.Path != "/a/1" || serr.Offset != 8 {
+		t.Errorf("expected \"/a/1\" at 8 but got %q at %d", serr.Path, serr.Offset)
+	}
+}
+
+func TestDecoder_shortReads(t *testing.T) {
+	type value struct {
+		I int32
+		F float64
+		S string
+		B []byte
+	}
+	exp := value{I: -70000, F: 1.5, S: strings.Repeat("s", 5000), B: bytes.Repeat([]byte{1}, 5000)}
+	bin, err := Marshal(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := MarshalBlock(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, d := range map[string]*Decoder{
+		"binary": NewDecoder(iotest.OneByteReader(bytes.NewReader(bin))),
+		"block":  NewBlockDecoder(iotest.OneByteReader(bytes.NewReader(block))),
+		"half":   NewDecoder(iotest.HalfReader(bytes.NewReader(bin))),
+	} {
+		var got value
+		if err := d.Decode(&got); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if !reflect.DeepEqual(exp, got) {
+			t.Errorf("%s: decoded value differs", name)
+		}
+	}
+
+	// A limit within a value which arrives in pieces.
+	d := NewDecoder(iotest.OneByteReader(bytes.NewReader(bin)))
+	d.MaxBytes = int64(len(bin) - 1)
+	var got value
+	var lerr *LimitError
+	if err := d.Decode(&got); !errors.As(err, &lerr) {
+		t.Errorf("expected *LimitError but got: %v", err)
+	} else if lerr.Limit != "MaxBytes" || lerr.Offset != d.MaxBytes {
+		t.Errorf("expected MaxBytes at %d but got %s at %d", d.MaxBytes, lerr.Limit, lerr.Offset)
+	}
+}
+
+func TestDecoder_EOF(t *testing.T) {
+	for name, d := range map[string]*Decoder{
+		"binary": NewDecoder(bytes.NewReader([]byte("SU\x01aSU\x01b"))),
+		"block":  NewBlockDecoder(strings.NewReader("[S][U][1][a]\n[S][U][1][b]\n")),
+		"bytes":  newBytesDecoder([]byte("SU\x01aSU\x01b"), false),
+	} {
+		for _, exp := range []string{"a", "b"} {
+			if s, err := d.DecodeString(); err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			} else if s != exp {
+				t.Errorf("%s: expected %q but got %q", name, exp, s)
+			}
+		}
+		if _, err := d.DecodeString(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF but got: %v", name, err)
+		}
+		if _, err := d.Token(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF from Token but got: %v", name, err)
+		}
+	}
+
+	for name, b := range map[string]string{
+		"marker": "[SU\x01a",
+		"length": "SI\x01",
+		"string": "SU\x05abc",
+		"int32":  "l\x01\x02",
+		"skip":   "[$U#U\x05\x01\x02",
+	} {
+		for dname, d := range map[string]*Decoder{
+			"binary": NewDecoder(strings.NewReader(b)),
+			"bytes":  newBytesDecoder([]byte(b), false),
+		} {
+			var err error
+			if name == "skip" {
+				err = d.Skip()
+			} else {
+				var v interface{}
+				err = d.Decode(&v)
+			}
+			var serr *SyntaxError
+			if !errors.As(err, &serr) || !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+				t.Errorf("%s %s: expected *SyntaxError wrapping only io.ErrUnexpectedEOF but got: %v", name, dname, err)
+			} else if serr.Offset != int64(len(b)) {
+				t.Errorf("%s %s: expected offset %d but got %d", name, dname, len(b), serr.Offset)
+			}
+		}
+	}
+
+	var v interface{}
+	if err := Unmarshal(nil, &v); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for empty input but got: %v", err)
+	}
+
+	// The input ends within a top-level Value, which reads a nested value.
+	for dname, d := range map[string]*Decoder{
+		"binary": NewDecoder(strings.NewReader("C")),
+		"bytes":  newBytesDecoder([]byte("C"), false),
+	} {
+		var nv nestedValue
+		var serr *SyntaxError
+		if err := d.Decode(&nv); !errors.As(err, &serr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("nested %s: expected *SyntaxError wrapping io.ErrUnexpectedEOF but got: %v", dname, err)
+		} else if serr.Offset != 1 {
+			t.Errorf("nested %s: expected offset 1 but got %d", dname, serr.Offset)
+		}
+	}
+
+	// An io.EOF from an unmarshaler is not the end of the input.
+	d := NewDecoder(strings.NewReader("SU\x01aSU\x01b"))
+	var eu eofUnmarshaler
+	var serr *SyntaxError
+	if err := d.Decode(&eu); err == io.EOF || !errors.As(err, &serr) || !errors.Is(err, errEOFUnmarshaler) {
+		t.Errorf("expected *SyntaxError wrapping the unmarshaler's error but got: %v", err)
+	} else if !errors.Is(err, io.ErrUnexpectedEOF) || serr.Offset != 4 {
+		t.Errorf("expected io.ErrUnexpectedEOF at offset 4 but got: %v at %d", err, serr.Offset)
+	}
+}
+
+// A nestedValue is a Value with a nested string value as its data.
+type nestedValue string
+
+func (v *nestedValue) UBJSONType() Marker { return CharMarker }
+
+func (v *nestedValue) MarshalUBJSON(e *Encoder) error { return e.EncodeString(string(*v)) }
+
+func (v *nestedValue) UnmarshalUBJSON(d *Decoder) error {
+	s, err := d.DecodeString()
+	*v = nestedValue(s)
+	return err
+}
+
+var errEOFUnmarshaler = fmt.Errorf("eofUnmarshaler: %w", io.EOF)
+
+// An eofUnmarshaler always fails with an error wrapping io.EOF.
+type eofUnmarshaler struct{}
+
+func (*eofUnmarshaler) UnmarshalText([]byte) error { return errEOFUnmarshaler }
+
+func TestUnmarshalSkipUnknownField(t *testing.T) {
+	bin := []byte{'{',
+		'U', 7, 'u', 'n', 'k', 'n', 'o', 'w', 'n', '[', '$', 'l', '#', 'U', 2, 0, 0, 0, 1, 0, 0, 0, 2,
+		'U', 1, 'A', 'U', 5,
+		'}'}
+	var v struct{ A int }
+	if err := Unmarshal(bin, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 5 {
+		t.Errorf("expected 5 but got %d", v.A)
+	}
+}
+
+func TestDecoder_Reset(t *testing.T) {
+	for _, block := range []bool{false, true} {
+		marshal, newDecoder := Marshal, NewDecoder
+		if block {
+			marshal, newDecoder = MarshalBlock, NewBlockDecoder
+		}
+		first, err := marshal([]int8{1, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := marshal(map[string]string{"a": "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Decode only part of the first input, leaving the rest buffered.
+		d := newDecoder(bytes.NewReader(first))
+		d.UseNumber = true
+		for i := 0; i < 2; i++ {
+			if _, err := d.Token(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		d.Reset(bytes.NewReader(second))
+		if !d.UseNumber {
+			t.Error("expected options to be preserved")
+		}
+		var m map[string]string
+		if err := d.Decode(&m); err != nil {
+			t.Fatalf("block=%t: %v", block, err)
+		}
+		if exp := map[string]string{"a": "b"}; !reflect.DeepEqual(exp, m) {
+			t.Errorf("block=%t: expected %v but got %v", block, exp, m)
+		}
+	}
+}
+
+func TestDecoder_containers_allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	bin := []byte{'[', '#', 'U', 2,
+		'[', '$', 'i', '#', 'U', 2, 1, 2,
+		'[', 'i', 3, 'i', 4, ']'}
+	r := bytes.NewReader(bin)
+	d := NewDecoder(r)
+	decodeInts := func(a *ArrayDecoder) error {
+		for a.NextElem() {
+			if _, err := a.DecodeInt8(); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}
+	decodeArrays := func(a *ArrayDecoder) error {
+		for a.NextElem() {
+			if err := a.DecodeArray(decodeInts); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(bin)
+		d.Reset(r)
+		if err := d.DecodeArray(decodeArrays); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations but got %f", allocs)
+	}
+}
+
+func TestUnmarshal_namedTypes(t *testing.T) {
+	v := namedTypes{S: "s", B: true, I: -300, U: 300, F: 1.5, I8: []namedInt8{1, -1}}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got namedTypes
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, got) {
+		t.Errorf("expected %+v but got %+v", v, got)
+	}
+}
+
+func TestUnmarshal_unsizedSlice(t *testing.T) {
+	bin := []byte{'[', 'S', 'U', 1, 'a', 'S', 'U', 1, 'b', ']'}
+	var got []string
+	if err := Unmarshal(bin, &got); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"a", "b"}; !reflect.DeepEqual(exp, got) {
+		t.Errorf("expected %v but got %v", exp, got)
+	}
+}
+
+func TestUnmarshalNoCopy_allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	type inner struct {
+		C float64
+		D [2]int8
+	}
+	var v struct {
+		A int
+		B string
+		E inner
+		F *inner
+		G time.Duration
+	}
+	v.A, v.B, v.E.C, v.F, v.G = 1, "b", 1.5, &inner{D: [2]int8{1, 2}}, time.Second
+	bin, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := UnmarshalNoCopy(bin, &v); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations but got %f", allocs)
+	}
+}
@@ -1,10 +1,18 @@
 package ubjson
 
 import (
+	"bytes"
+	"encoding"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 )
 
 // Encoder provides methods for encoding UBJSON data types.
@@ -14,18 +22,69 @@ type Encoder struct {
 	// Normally (*Encoder).writeMarker, but overridden by
 	// containers to do validation and optimization.
 	writeValType func(Marker) error
+	// Encode map entries in sorted key order, so that equal maps always
+	// produce identical output. Otherwise, map iteration order is random.
+	// Marshal and MarshalBlock always sort.
+	SortMapKeys bool
+	// Representation of time.Time and time.Duration values. Defaults to
+	// TimeText.
+	TimeFormat TimeFormat
+	// Disables flushing after each top-level value, so that output is only
+	// written when the buffer is full, or on explicit calls to Flush.
+	ManualFlush bool
+
+	// Nesting depth of values being encoded. Only top-level values (depth 0)
+	// are flushed.
+	depth int
+	// Buffer size from NewEncoderSize, for buffers replaced by Reset.
+	size int
+	// Containers opened by WriteToken.
+	tokens []tokenContainer
+	// Container encoders returned by ArrayType and ObjectType, which are
+	// reused for each container since only one may be open at a time.
+	array  *ArrayEncoder
+	object *ObjectEncoder
 }
 
-// NewEncoder returns a new Encoder.
+// NewEncoder returns a new Encoder. Output is buffered, and flushed after each
+// top-level value unless ManualFlush is set. A *bytes.Buffer is written to
+// directly, without an intermediate buffer.
 func NewEncoder(w io.Writer) *Encoder {
-	e := &Encoder{writer: newBinaryWriter(w)}
+	return NewEncoderSize(w, 0)
+}
+
+// NewEncoderSize returns a new Encoder, like NewEncoder, with a buffer of at
+// least size bytes.
+func NewEncoderSize(w io.Writer, size int) *Encoder {
+	e := &Encoder{writer: newBinaryWriter(w, size), size: size}
 	e.writeValType = e.writeMarker
 	return e
 }
 
+// Flush writes any buffered output to the underlying io.Writer.
+func (e *Encoder) Flush() error {
+	return e.writer.Flush()
+}
+
+// Reset discards any unflushed output and state, and prepares e to write to w,
+// reusing its buffer when possible. Options and the buffer size are preserved.
+// Reset must only be called on Encoders returned by NewEncoder, NewEncoderSize,
+// or NewBlockEncoder.
+func (e *Encoder) Reset(w io.Writer) {
+	switch ew := e.writer.(type) {
+	case *binaryWriter:
+		ew.bufferedWriter = resetBufferedWriter(ew.bufferedWriter, w, e.size)
+	case *blockWriter:
+		ew.bufferedWriter = resetBufferedWriter(ew.bufferedWriter, w, e.size)
+		ew.indent = 0
+	}
+	e.depth = 0
+	e.tokens = e.tokens[:0]
+}
+
 // NewBlockEncoder returns a new block-notation Encoder.
 func NewBlockEncoder(w io.Writer) *Encoder {
-	e := &Encoder{writer: newBlockWriter(w)}
+	e := &Encoder{writer: newBlockWriter(w, 0)}
 	e.writeValType = e.writeMarker
 	return e
 }
@@ -66,7 +125,10 @@ func (e *Encoder) encode(m Marker, encodeData func(*Encoder) error) error {
 	if err := e.writeValType(m); err != nil {
 		return err
 	}
-	if err := encodeData(e); err != nil {
+	e.depth++
+	err := encodeData(e)
+	e.depth--
+	if err != nil || e.depth > 0 || e.ManualFlush {
 		return err
 	}
 	return e.Flush()
@@ -102,7 +164,13 @@ func (e *Encoder) EncodeInt64(v int64) error {
 
 // EncodeInt encodes an int in the smallest possible integer format (U,i,L,l,L).
 func (e *Encoder) EncodeInt(v int) error {
-	m := smallestIntMarker(int64(v))
+	return e.encodeSmallestInt(int64(v))
+}
+
+// encodeSmallestInt encodes v in the smallest possible integer format
+// (U,i,L,l,L).
+func (e *Encoder) encodeSmallestInt(v int64) error {
+	m := smallestIntMarker(v)
 	switch m {
 	case UInt8Marker:
 		return e.EncodeUInt8(uint8(v))
@@ -113,12 +181,38 @@ func (e *Encoder) EncodeInt(v int) error {
 	case Int32Marker:
 		return e.EncodeInt32(int32(v))
 	case Int64Marker:
-		return e.EncodeInt64(int64(v))
+		return e.EncodeInt64(v)
 	default:
 		return fmt.Errorf("unsupported marker: %s", string(m))
 	}
 }
 
+// EncodeUInt encodes a uint in the smallest possible integer format
+// (U,I,l,L), or as a high precision number 'H' if it exceeds math.MaxInt64.
+func (e *Encoder) EncodeUInt(v uint) error {
+	return e.EncodeUInt64(uint64(v))
+}
+
+// EncodeUInt16 encodes a uint16 in the smallest possible integer format (U,I,l).
+func (e *Encoder) EncodeUInt16(v uint16) error {
+	return e.encodeSmallestInt(int64(v))
+}
+
+// EncodeUInt32 encodes a uint32 in the smallest possible integer format
+// (U,I,l,L).
+func (e *Encoder) EncodeUInt32(v uint32) error {
+	return e.encodeSmallestInt(int64(v))
+}
+
+// EncodeUInt64 encodes a uint64 in the smallest possible integer format
+// (U,I,l,L), or as a high precision number 'H' if it exceeds math.MaxInt64.
+func (e *Encoder) EncodeUInt64(v uint64) error {
+	if v > math.MaxInt64 {
+		return e.EncodeHighPrecNum(strconv.FormatUint(v, 10))
+	}
+	return e.encodeSmallestInt(int64(v))
+}
+
 // EncodeFloat32 encodes a float32 as an 'f'.
 func (e *Encoder) EncodeFloat32(v float32) error {
 	return e.encode(Float32Marker, func(*Encoder) error {
@@ -175,6 +269,18 @@ func elementMarkerFor(t reflect.Type) Marker {
 		return CharMarker
 	case reflect.TypeOf(HighPrecNumber("")):
 		return HighPrecNumMarker
+	case timeType, durationType:
+		// Depends on TimeFormat.
+		return 0
+	case bigIntType, bigFloatType, bigRatType:
+		return HighPrecNumMarker
+	case numberType, rawValueType:
+		// Preserves the marker of each value.
+		return 0
+	}
+	if t.Kind() != reflect.Ptr && hasPtrMarshaler(t) {
+		// Encoded by the methods of *t.
+		return 0
 	}
 	k := t.Kind()
 	if v, ok := reflect.New(t).Interface().(Value); ok {
@@ -184,10 +290,21 @@ func elementMarkerFor(t reflect.Type) Marker {
 			return 0
 		}
 	}
+	if k != reflect.Ptr {
+		if t.Implements(textMarshalerType) {
+			return StringMarker
+		}
+		if t.Implements(binaryMarshalerType) {
+			return ArrayStartMarker
+		}
+	}
 
 	switch k {
 	case reflect.Bool, reflect.Int:
 		return 0
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// Encoded in the smallest possible integer format.
+		return 0
 
 	case reflect.String:
 		return StringMarker
@@ -264,7 +381,7 @@ func (a *ArrayEncoder) End() error {
 		return fmt.Errorf("unable to end array of length %d after %d elements", a.len, a.count)
 	}
 
-	return a.Flush()
+	return a.flushEnd()
 }
 
 // An ObjectEncoder supplements an Encoder with EncodeKey() and End() methods,
@@ -308,6 +425,34 @@ func (o *ObjectEncoder) writeValType(m Marker) error {
 
 // EncodeKey encodes an object key.
 func (o *ObjectEncoder) EncodeKey(key string) error {
+	if err := o.nextKey(); err != nil {
+		return err
+	}
+	if err := o.writeNewLine(); err != nil {
+		return err
+	}
+
+	return o.writeString(key)
+}
+
+// encodeKey is like EncodeKey, but writes binKey, the binary encoding of key,
+// directly when possible.
+func (o *ObjectEncoder) encodeKey(key string, binKey []byte) error {
+	if err := o.nextKey(); err != nil {
+		return err
+	}
+	if w, ok := o.writer.(*binaryWriter); ok {
+		return w.write(binKey)
+	}
+	if err := o.writeNewLine(); err != nil {
+		return err
+	}
+
+	return o.writeString(key)
+}
+
+// nextKey increments and validates the count for a key.
+func (o *ObjectEncoder) nextKey() error {
 	o.count++
 
 	if o.len >= 0 {
@@ -318,12 +463,7 @@ func (o *ObjectEncoder) EncodeKey(key string) error {
 	if o.count%2 == 0 {
 		return errors.New("expected value not key")
 	}
-
-	if err := o.writeNewLine(); err != nil {
-		return err
-	}
-
-	return o.writeString(key)
+	return nil
 }
 
 // End checks the length or writes an end maker.
@@ -342,7 +482,7 @@ func (o *ObjectEncoder) End() error {
 		return fmt.Errorf("unable to end map of %d entries after %d", o.len, o.count/2)
 	}
 
-	return o.Flush()
+	return o.flushEnd()
 }
 
 // Object begins encoding an object container.
@@ -357,7 +497,8 @@ func (e *Encoder) ObjectLen(len int) (*ObjectEncoder, error) {
 }
 
 // ObjectType begins encoding a strongly-typed object container with a specified
-// length.
+// length. The ObjectEncoder is reused by e for subsequent objects, so it must
+// not be used after End.
 func (e *Encoder) ObjectType(valType Marker, len int) (*ObjectEncoder, error) {
 	e.incIndent()
 
@@ -365,9 +506,14 @@ func (e *Encoder) ObjectType(valType Marker, len int) (*ObjectEncoder, error) {
 		return nil, err
 	}
 
-	o := &ObjectEncoder{valType: valType, len: len}
-	o.Encoder.writer = e.writer
-	o.Encoder.writeValType = o.writeValType
+	o := e.object
+	if o == nil {
+		o = &ObjectEncoder{}
+		o.Encoder.writeValType = o.writeValType
+		e.object = o
+	}
+	e.initChild(&o.Encoder)
+	o.valType, o.len, o.count = valType, len, 0
 	return o, nil
 }
 
@@ -383,7 +529,8 @@ func (e *Encoder) ArrayLen(len int) (*ArrayEncoder, error) {
 
 // ArrayType begins encoding a strongly-typed array container with a specified
 // length. When encoding a single byte element type, actual elements are
-// optimized away, and End() must be called immediately.
+// optimized away, and End() must be called immediately. The ArrayEncoder is
+// reused by e for subsequent arrays, so it must not be used after End.
 func (e *Encoder) ArrayType(elemType Marker, len int) (*ArrayEncoder, error) {
 	e.incIndent()
 
@@ -391,12 +538,37 @@ func (e *Encoder) ArrayType(elemType Marker, len int) (*ArrayEncoder, error) {
 		return nil, err
 	}
 
-	a := &ArrayEncoder{elemType: elemType, len: len}
-	a.Encoder.writer = e.writer
-	a.Encoder.writeValType = a.writeElemType
+	a := e.array
+	if a == nil {
+		a = &ArrayEncoder{}
+		a.Encoder.writeValType = a.writeElemType
+		e.array = a
+	}
+	e.initChild(&a.Encoder)
+	a.elemType, a.len, a.count = elemType, len, 0
 	return a, nil
 }
 
+// initChild initializes c to encode the contents of a container, with the same
+// writer and options as e.
+func (e *Encoder) initChild(c *Encoder) {
+	c.writer = e.writer
+	c.depth = e.depth + 1
+	c.SortMapKeys = e.SortMapKeys
+	c.TimeFormat = e.TimeFormat
+	c.ManualFlush = e.ManualFlush
+	c.tokens = c.tokens[:0]
+}
+
+// flushEnd flushes after the end of a container encoded by e, if it was opened
+// at the top level, like the end of any other top-level value.
+func (e *Encoder) flushEnd() error {
+	if e.depth > 1 || e.ManualFlush {
+		return nil
+	}
+	return e.Flush()
+}
+
 func (e *Encoder) writeContainer(elemType Marker, len int) error {
 	// Optimize type?
 	if elemType != 0 {
@@ -421,7 +593,13 @@ func (e *Encoder) writeContainer(elemType Marker, len int) error {
 }
 
 // Encode encodes v into universal binary json. Types implementing Value will be
-// encoded via their MarshalUBJSON method.
+// encoded via their MarshalUBJSON method. Then time.Time and time.Duration
+// are encoded according to TimeFormat, and big.Int, big.Float, and big.Rat as
+// high precision numbers (H). Otherwise, types implementing
+// encoding.TextMarshaler are encoded as strings (S), and then types
+// implementing encoding.BinaryMarshaler as strongly typed byte arrays ([$U#).
+// Methods with pointer receivers are used too, via a copy for values which are
+// not addressable, such as map values.
 func (e *Encoder) Encode(v interface{}) error {
 	if v == nil {
 		return e.EncodeNull()
@@ -429,147 +607,541 @@ func (e *Encoder) Encode(v interface{}) error {
 	if val, ok := v.(Value); ok {
 		return e.EncodeValue(val)
 	}
-	switch t := v.(type) {
-	case string:
-		return e.EncodeString(t)
-	case bool:
-		return e.EncodeBool(t)
-	case int:
-		return e.EncodeInt(t)
-	case int8:
-		return e.EncodeInt8(t)
-	case uint8:
-		return e.EncodeUInt8(t)
-	case int16:
-		return e.EncodeInt16(t)
-	case int32:
-		return e.EncodeInt32(t)
-	case int64:
-		return e.EncodeInt64(t)
-	case float32:
-		return e.EncodeFloat32(t)
-	case float64:
-		return e.EncodeFloat64(t)
-	case Char:
-		return e.EncodeChar(byte(t))
-	case HighPrecNumber:
-		return e.EncodeHighPrecNum(string(t))
-	}
-
-	// Containers
 	value := reflect.ValueOf(v)
-	switch value.Kind() {
-	case reflect.Array, reflect.Slice:
-		return e.encode(ArrayStartMarker, encodeArray(value))
+	return typeEncoder(value.Type())(e, value)
+}
 
-	case reflect.Map:
-		if k := value.Type().Key().Kind(); k != reflect.String {
-			return fmt.Errorf("unable to encode map of type %s: key reflect.Kind must be reflect.String but is %s", value.Type(), k)
-		}
-		return e.encode(ObjectStartMarker, encodeMap(value))
+// An encoderFunc encodes a value of a particular type.
+type encoderFunc func(e *Encoder, v reflect.Value) error
 
-	case reflect.Struct:
-		return e.encode(ObjectStartMarker, encodeStruct(value))
+// Based on 'encoding/json/encode.go'.
+var encoderCache sync.Map // map[reflect.Type]encoderFunc
 
-	case reflect.Ptr:
-		if value.IsNil() {
-			return e.EncodeNull()
-		}
-		return e.Encode(value.Elem().Interface())
+// typeEncoder returns the cached encoderFunc for t, compiling it if necessary.
+// Based on 'encoding/json/encode.go'.
+func typeEncoder(t reflect.Type) encoderFunc {
+	if fi, ok := encoderCache.Load(t); ok {
+		return fi.(encoderFunc)
+	}
+
+	// To deal with recursive types, populate the map with an indirect func
+	// before we build it. This type waits on the real func (f) to be ready
+	// and then calls it. This indirect func is only used for recursive types.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *Encoder, v reflect.Value) error {
+		wg.Wait()
+		return f(e, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
 	}
 
-	return fmt.Errorf("unable to encode value: %v", v)
+	// Compute the real encoder and replace the indirect func with it.
+	f = newTypeEncoder(t)
+	wg.Done()
+	encoderCache.Store(t, f)
+	return f
 }
 
-func encodeArray(arrayValue reflect.Value) func(*Encoder) error {
-	return func(e *Encoder) error {
-		var elemType reflect.Type
-		if arrayValue.Type().Elem().Kind() != reflect.Interface {
-			elemType = arrayValue.Type().Elem()
+// newTypeEncoder compiles an encoderFunc for t, following the precedence
+// documented by Encode.
+func newTypeEncoder(t reflect.Type) encoderFunc {
+	if t.Kind() == reflect.Interface {
+		// Depends on the dynamic type.
+		return encodeInterface
+	}
+	if t.Kind() != reflect.Ptr && hasPtrMarshaler(t) {
+		return newPtrMarshalerEncoder(t, typeEncoder(reflect.PtrTo(t)))
+	}
+	if t.Implements(valueType) {
+		return func(e *Encoder, v reflect.Value) error {
+			if v.Kind() == reflect.Ptr && v.IsNil() {
+				return e.EncodeNull()
+			}
+			return e.EncodeValue(v.Interface().(Value))
+		}
+	}
+	switch t {
+	case timeType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeTime(v.Interface().(time.Time))
+		}
+	case durationType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeDuration(time.Duration(v.Int()))
+		}
+	case bigIntType, bigFloatType, bigRatType:
+		// Encoded via a pointer, to avoid copying.
+		ptrEnc := typeEncoder(reflect.PtrTo(t))
+		return func(e *Encoder, v reflect.Value) error {
+			if !v.CanAddr() {
+				pv := reflect.New(t)
+				pv.Elem().Set(v)
+				v = pv.Elem()
+			}
+			return ptrEnc(e, v.Addr())
+		}
+	case reflect.PtrTo(bigIntType):
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				return e.EncodeNull()
+			}
+			return e.EncodeBigInt(v.Interface().(*big.Int))
+		}
+	case reflect.PtrTo(bigFloatType):
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				return e.EncodeNull()
+			}
+			return e.EncodeBigFloat(v.Interface().(*big.Float))
+		}
+	case reflect.PtrTo(bigRatType):
+		return func(e *Encoder, v reflect.Value) error {
+			if v.IsNil() {
+				return e.EncodeNull()
+			}
+			return e.EncodeBigRat(v.Interface().(*big.Rat))
 		}
+	}
+	if t.Implements(textMarshalerType) {
+		return encodeTextMarshaler
+	}
+	if t.Implements(binaryMarshalerType) {
+		return encodeBinaryMarshaler
+	}
+	switch t {
+	case charType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeChar(byte(v.Uint()))
+		}
+	case highPrecNumType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeHighPrecNum(v.String())
+		}
+	case numberType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeNumber(v.Interface().(Number))
+		}
+	case rawValueType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeRawValue(RawValue(v.Bytes()))
+		}
+	}
 
-		var ae *ArrayEncoder
-		var err error
-		if m := elementMarkerFor(elemType); m == 0 {
-			ae, err = e.ArrayLen(arrayValue.Len())
-		} else {
-			ae, err = e.ArrayType(m, arrayValue.Len())
+	switch t.Kind() {
+	case reflect.String:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeString(v.String())
 		}
-		if err != nil {
-			return err
+	case reflect.Bool:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeBool(v.Bool())
+		}
+	case reflect.Int:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeInt(int(v.Int()))
+		}
+	case reflect.Int8:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeInt8(int8(v.Int()))
+		}
+	case reflect.Int16:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeInt16(int16(v.Int()))
+		}
+	case reflect.Int32:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeInt32(int32(v.Int()))
+		}
+	case reflect.Int64:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeInt64(v.Int())
+		}
+	case reflect.Uint8:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeUInt8(uint8(v.Uint()))
+		}
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeUInt64(v.Uint())
+		}
+	case reflect.Float32:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeFloat32(float32(v.Float()))
+		}
+	case reflect.Float64:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.EncodeFloat64(v.Float())
 		}
 
-		for i := 0; i < arrayValue.Len(); i++ {
-			if err := ae.Encode(arrayValue.Index(i).Interface()); err != nil {
-				return fmt.Errorf("failed to encode array element %d: %w", i, err)
+	// Containers
+	case reflect.Array, reflect.Slice:
+		return newArrayEncoder(t)
+	case reflect.Map:
+		if kt := t.Key(); !encodableKeyType(kt) {
+			return func(*Encoder, reflect.Value) error {
+				return &UnsupportedTypeError{Type: t, msg: fmt.Sprintf("unable to encode map of type %s: key type must be a string, an integer, or implement encoding.TextMarshaler, but is %s", t, kt)}
 			}
 		}
+		return newMapEncoder(t)
+	case reflect.Struct:
+		return newStructEncoder(t)
+	case reflect.Ptr:
+		return newPtrEncoder(t)
+	}
+	return func(e *Encoder, v reflect.Value) error {
+		return &UnsupportedTypeError{Type: t, msg: fmt.Sprintf("unable to encode value: %v", v)}
+	}
+}
 
-		return ae.End()
+var valueType = reflect.TypeOf((*Value)(nil)).Elem()
+
+// hasPtrMarshaler returns true if *t implements Value, encoding.TextMarshaler,
+// or encoding.BinaryMarshaler, but t does not.
+func hasPtrMarshaler(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	for _, it := range []reflect.Type{valueType, textMarshalerType, binaryMarshalerType} {
+		if !t.Implements(it) && pt.Implements(it) {
+			return true
+		}
 	}
+	return false
 }
 
-func encodeMap(mapValue reflect.Value) func(*Encoder) error {
-	return func(e *Encoder) error {
-		var elemType reflect.Type
-		valKind := mapValue.Type().Elem().Kind()
-		if valKind != reflect.Interface {
-			elemType = mapValue.Type().Elem()
+// newPtrMarshalerEncoder returns an encoderFunc which applies ptrEnc to the
+// address of addressable values, and to the address of a copy of other values,
+// so that they are encoded the same way.
+func newPtrMarshalerEncoder(t reflect.Type, ptrEnc encoderFunc) encoderFunc {
+	return func(e *Encoder, v reflect.Value) error {
+		if v.CanAddr() {
+			return ptrEnc(e, v.Addr())
 		}
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		return ptrEnc(e, p)
+	}
+}
+
+// encodeInterface encodes the dynamic value of v, or null if v is nil.
+func encodeInterface(e *Encoder, v reflect.Value) error {
+	if v.IsNil() {
+		return e.EncodeNull()
+	}
+	v = v.Elem()
+	return typeEncoder(v.Type())(e, v)
+}
 
-		keys := mapKeys(mapValue)
+// encodeTextMarshaler encodes v as a string (S), or null if v is a nil
+// pointer.
+func encodeTextMarshaler(e *Encoder, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.EncodeNull()
+	}
+	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return fmt.Errorf("failed to marshal text: %w", err)
+	}
+	return e.EncodeString(string(b))
+}
 
-		marker := elementMarkerFor(elemType)
-		var o *ObjectEncoder
-		var err error
-		if marker != 0 {
-			o, err = e.ObjectType(marker, len(keys))
-		} else {
-			o, err = e.ObjectLen(len(keys))
+// encodeBinaryMarshaler encodes v as a strongly typed byte array ([$U#), or
+// null if v is a nil pointer.
+func encodeBinaryMarshaler(e *Encoder, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.EncodeNull()
+	}
+	b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal binary: %w", err)
+	}
+	return e.EncodeBytes(b)
+}
+
+// newPtrEncoder returns an encoderFunc for the pointer type t, which encodes
+// the element, or null if the pointer is nil.
+func newPtrEncoder(t reflect.Type) encoderFunc {
+	elemEnc := typeEncoder(t.Elem())
+	return func(e *Encoder, v reflect.Value) error {
+		if v.IsNil() {
+			return e.EncodeNull()
 		}
-		if err != nil {
-			return err
+		return elemEnc(e, v.Elem())
+	}
+}
+
+// encodeContainer is like encode, for the data of containers encoded by an
+// encoderFunc, without allocating a closure.
+func (e *Encoder) encodeContainer(m Marker, encodeData encoderFunc, v reflect.Value) error {
+	if err := e.writeValType(m); err != nil {
+		return err
+	}
+	e.depth++
+	err := encodeData(e, v)
+	e.depth--
+	if err != nil || e.depth > 0 || e.ManualFlush {
+		return err
+	}
+	return e.Flush()
+}
+
+// An arrayEncoder encodes arrays and slices with a particular element type.
+type arrayEncoder struct {
+	// Strong type of elements, or 0 for none.
+	elemMarker Marker
+	elemEnc    encoderFunc
+	// Whether slices are written in bulk.
+	bulk bool
+}
+
+func newArrayEncoder(t reflect.Type) encoderFunc {
+	var elemType reflect.Type
+	if t.Elem().Kind() != reflect.Interface {
+		elemType = t.Elem()
+	}
+	ae := &arrayEncoder{elemMarker: elementMarkerFor(elemType)}
+	if t.Kind() == reflect.Slice {
+		_, ae.bulk = bulkSliceType(t)
+	}
+	if !ae.bulk {
+		ae.elemEnc = typeEncoder(t.Elem())
+	}
+	return func(e *Encoder, v reflect.Value) error {
+		return e.encodeContainer(ArrayStartMarker, ae.encodeData, v)
+	}
+}
+
+func (ae *arrayEncoder) encodeData(e *Encoder, arrayValue reflect.Value) error {
+	if ae.bulk {
+		_, err := e.writeSlice(arrayValue)
+		return err
+	}
+
+	var a *ArrayEncoder
+	var err error
+	if ae.elemMarker == 0 {
+		a, err = e.ArrayLen(arrayValue.Len())
+	} else {
+		a, err = e.ArrayType(ae.elemMarker, arrayValue.Len())
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < arrayValue.Len(); i++ {
+		if err := ae.elemEnc(&a.Encoder, arrayValue.Index(i)); err != nil {
+			prependPath(err, strconv.Itoa(i))
+			return fmt.Errorf("failed to encode array element %d: %w", i, err)
 		}
+	}
+
+	return a.End()
+}
 
-		for _, key := range keys {
-			if err := o.EncodeKey(key.String()); err != nil {
-				return fmt.Errorf("failed to encode key %q: %w", key.String(), err)
+// A mapEncoder encodes maps with a particular value type.
+type mapEncoder struct {
+	// Strong type of values, or 0 for none.
+	elemMarker Marker
+	elemEnc    encoderFunc
+}
+
+func newMapEncoder(t reflect.Type) encoderFunc {
+	var elemType reflect.Type
+	if t.Elem().Kind() != reflect.Interface {
+		elemType = t.Elem()
+	}
+	me := &mapEncoder{
+		elemMarker: elementMarkerFor(elemType),
+		elemEnc:    typeEncoder(t.Elem()),
+	}
+	return func(e *Encoder, v reflect.Value) error {
+		return e.encodeContainer(ObjectStartMarker, me.encodeData, v)
+	}
+}
+
+func (me *mapEncoder) encodeData(e *Encoder, mapValue reflect.Value) error {
+	var o *ObjectEncoder
+	var err error
+	if me.elemMarker != 0 {
+		o, err = e.ObjectType(me.elemMarker, mapValue.Len())
+	} else {
+		o, err = e.ObjectLen(mapValue.Len())
+	}
+	if err != nil {
+		return err
+	}
+
+	if !e.SortMapKeys {
+		iter := mapValue.MapRange()
+		for iter.Next() {
+			name, err := resolveKeyName(iter.Key())
+			if err != nil {
+				return fmt.Errorf("failed to resolve key %v: %w", iter.Key(), err)
 			}
-			if err := o.Encode(mapValue.MapIndex(key).Interface()); err != nil {
-				return fmt.Errorf("failed to encode value for key %q: %w", key.String(), err)
+			if err := me.encodeEntry(o, name, iter.Value()); err != nil {
+				return err
 			}
 		}
-
 		return o.End()
 	}
+
+	keys := mapValue.MapKeys()
+	entries := make([]mapEntry, len(keys))
+	for i, key := range keys {
+		name, err := resolveKeyName(key)
+		if err != nil {
+			return fmt.Errorf("failed to resolve key %v: %w", key, err)
+		}
+		entries[i] = mapEntry{key: key, name: name}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	for _, entry := range entries {
+		if err := me.encodeEntry(o, entry.name, mapValue.MapIndex(entry.key)); err != nil {
+			return err
+		}
+	}
+
+	return o.End()
 }
 
-// Overridden for tests.
-var mapKeys = func(mapValue reflect.Value) []reflect.Value {
-	return mapValue.MapKeys()
+func (me *mapEncoder) encodeEntry(o *ObjectEncoder, name string, v reflect.Value) error {
+	if err := o.EncodeKey(name); err != nil {
+		return fmt.Errorf("failed to encode key %q: %w", name, err)
+	}
+	if err := me.elemEnc(&o.Encoder, v); err != nil {
+		prependPath(err, name)
+		return fmt.Errorf("failed to encode value for key %q: %w", name, err)
+	}
+	return nil
 }
 
-func encodeStruct(structValue reflect.Value) func(*Encoder) error {
-	return func(e *Encoder) error {
-		o, err := e.Object()
-		if err != nil {
-			return err
+// A mapEntry is a map key with its resolved object key name.
+type mapEntry struct {
+	key  reflect.Value
+	name string
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// encodableKeyType returns true if map keys of type kt can be encoded as object
+// keys.
+func encodableKeyType(kt reflect.Type) bool {
+	switch kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return kt.Implements(textMarshalerType)
+}
+
+// resolveKeyName returns the object key for a map key. Strings are used
+// directly, then encoding.TextMarshalers are preferred, and integers are
+// formatted in decimal.
+// Based on 'encoding/json/encode.go'.
+func resolveKeyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
 		}
-		fs := cachedTypeFields(structValue.Type())
-		for _, name := range fs.names {
-			i, ok := fs.indexByName[name]
-			if !ok {
-				panic("invalid cached type info: no index for field " + name)
-			}
-			if err := o.EncodeKey(name); err != nil {
-				return fmt.Errorf("failed to encode key %q: %w", name, err)
-			}
-			val := structValue.Field(i).Interface()
-			if err := o.Encode(val); err != nil {
-				return fmt.Errorf("failed to encode value for key %q: %w", name, err)
+		buf, err := tm.MarshalText()
+		return string(buf), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	panic("unexpected map key type " + k.Type().String())
+}
+
+// A structEncoder encodes structs of a particular type, with an encoderFunc
+// for each field.
+type structEncoder struct {
+	fields   fields
+	encoders []encoderFunc
+	// Binary encoded keys.
+	keys [][]byte
+}
+
+func newStructEncoder(t reflect.Type) encoderFunc {
+	se := &structEncoder{fields: cachedTypeFields(t)}
+	se.encoders = make([]encoderFunc, len(se.fields.list))
+	se.keys = make([][]byte, len(se.fields.list))
+	for i, f := range se.fields.list {
+		se.encoders[i] = typeEncoder(typeByIndex(t, f.index))
+		var buf bytes.Buffer
+		if err := newBinaryWriter(&buf, 0).writeString(f.name); err != nil {
+			panic(err)
+		}
+		se.keys[i] = buf.Bytes()
+	}
+	return func(e *Encoder, v reflect.Value) error {
+		return e.encodeContainer(ObjectStartMarker, se.encodeData, v)
+	}
+}
+
+func (se *structEncoder) encodeData(e *Encoder, structValue reflect.Value) error {
+	o, err := e.Object()
+	if err != nil {
+		return err
+	}
+	for i := range se.fields.list {
+		f := &se.fields.list[i]
+		fv, ok := fieldByIndex(structValue, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if err := o.encodeKey(f.name, se.keys[i]); err != nil {
+			return fmt.Errorf("failed to encode key %q: %w", f.name, err)
+		}
+		if f.asString {
+			if fv.Kind() == reflect.Ptr && fv.IsNil() {
+				err = o.EncodeNull()
+			} else {
+				err = o.EncodeString(formatScalar(reflect.Indirect(fv)))
 			}
+		} else if f.hasTimeFormat {
+			tf := o.TimeFormat
+			o.TimeFormat = f.timeFormat
+			err = se.encoders[i](&o.Encoder, fv)
+			o.TimeFormat = tf
+		} else {
+			err = se.encoders[i](&o.Encoder, fv)
+		}
+		if err != nil {
+			prependPath(err, f.name)
+			return fmt.Errorf("failed to encode value for key %q: %w", f.name, err)
 		}
+	}
 
-		return o.End()
+	return o.End()
+}
+
+// formatScalar formats a bool, integer, or float value as a string, for fields
+// with the 'string' tag option.
+func formatScalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
 	}
+	panic("formatScalar: unsupported kind " + v.Kind().String())
 }
@@ -1,9 +1,13 @@
 package ubjson
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 	"unicode/utf8"
 )
 
@@ -182,3 +186,466 @@ func Test_elementMarkerFor(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshalUnsupportedMapKey(t *testing.T) {
+	if b, err := Marshal(map[float64]int{1.5: 1}); err == nil {
+		t.Errorf("expected error but got: %x", b)
+	}
+}
+
+func TestMarshalUnsupportedType(t *testing.T) {
+	v := struct {
+		M map[string][]interface{}
+	}{map[string][]interface{}{"a/b": {1, make(chan int)}}}
+	var uerr *UnsupportedTypeError
+	if _, err := Marshal(v); !errors.As(err, &uerr) {
+		t.Fatalf("expected *UnsupportedTypeError but got: %v", err)
+	}
+	if uerr.Path != "/M/a~1b/1" {
+		t.Errorf("expected path \"/M/a~1b/1\" but got %q", uerr.Path)
+	}
+	if uerr.Type != reflect.TypeOf(make(chan int)) {
+		t.Errorf("expected type chan int but got %s", uerr.Type)
+	}
+}
+
+func TestEncoder_SortMapKeys(t *testing.T) {
+	m := map[int]bool{}
+	for i := 0; i < 20; i++ {
+		m[i] = true
+	}
+	exp, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SortMapKeys = true
+		if err := e.Encode([]interface{}{m}); err != nil {
+			t.Fatal(err)
+		}
+		// Skip the array header.
+		if got := buf.Bytes()[4:]; !bytes.Equal(exp, got) {
+			t.Fatalf("expected identical output:\n%x\n%x", exp, got)
+		}
+	}
+	if !bytes.HasPrefix(exp, []byte{'{', '#', 'U', 20, 'U', 0x01, '0', 'T', 'U', 0x01, '1', 'T', 'U', 0x02, '1', '0', 'T'}) {
+		t.Errorf("expected keys sorted as strings, but got: %q", exp)
+	}
+}
+
+// A countingWriter counts calls to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestEncoder_flush(t *testing.T) {
+	v := make([]interface{}, 10000)
+	for i := range v {
+		v[i] = i
+	}
+
+	var w countingWriter
+	e := NewEncoderSize(&w, 1<<16)
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected a single write but got %d", w.writes)
+	}
+	exp, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exp, w.Bytes()) {
+		t.Error("expected output to match Marshal")
+	}
+
+	// The buffer size is kept when Reset replaces a direct *bytes.Buffer.
+	var buf bytes.Buffer
+	e = NewEncoderSize(&buf, 1<<16)
+	w = countingWriter{}
+	e.Reset(&w)
+	if err := e.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected a single write after Reset but got %d", w.writes)
+	}
+
+	w = countingWriter{}
+	e = NewEncoder(&w)
+	e.ManualFlush = true
+	for i := 0; i < 3; i++ {
+		if err := e.Encode(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.writes != 0 {
+		t.Errorf("expected no writes before Flush but got %d", w.writes)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []byte{'U', 0, 'U', 1, 'U', 2}; !bytes.Equal(exp, w.Bytes()) {
+		t.Errorf("expected %v but got %v", exp, w.Bytes())
+	}
+}
+
+func TestEncoder_containersFlush(t *testing.T) {
+	encodeArray := func(a *ArrayEncoder, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := a.EncodeUInt8(1); err != nil {
+			return err
+		}
+		if err := a.EncodeUInt8(2); err != nil {
+			return err
+		}
+		return a.End()
+	}
+	encodeObject := func(o *ObjectEncoder, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := o.EncodeKey("a"); err != nil {
+			return err
+		}
+		if err := o.EncodeUInt8(1); err != nil {
+			return err
+		}
+		return o.End()
+	}
+	for name, tc := range map[string]struct {
+		encode func(e *Encoder) error
+		exp    string
+	}{
+		"Array":      {func(e *Encoder) error { return encodeArray(e.Array()) }, "U\x01U\x02]"},
+		"ArrayLen":   {func(e *Encoder) error { return encodeArray(e.ArrayLen(2)) }, "#U\x02U\x01U\x02"},
+		"ArrayType":  {func(e *Encoder) error { return encodeArray(e.ArrayType(UInt8Marker, 2)) }, "$U#U\x02\x01\x02"},
+		"Object":     {func(e *Encoder) error { return encodeObject(e.Object()) }, "U\x01aU\x01}"},
+		"ObjectLen":  {func(e *Encoder) error { return encodeObject(e.ObjectLen(1)) }, "#U\x01U\x01aU\x01"},
+		"ObjectType": {func(e *Encoder) error { return encodeObject(e.ObjectType(UInt8Marker, 1)) }, "$U#U\x01U\x01a\x01"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Not a *bytes.Buffer, so output is buffered.
+			var w countingWriter
+			e := NewEncoder(&w)
+			if err := tc.encode(e); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); got != tc.exp {
+				t.Errorf("expected %q to be flushed but got %q", tc.exp, got)
+			}
+
+			w = countingWriter{}
+			e.Reset(&w)
+			e.ManualFlush = true
+			if err := tc.encode(e); err != nil {
+				t.Fatal(err)
+			}
+			if w.writes != 0 {
+				t.Errorf("expected no writes before Flush but got %d", w.writes)
+			}
+		})
+	}
+}
+
+func TestEncoder_bytesBuffer(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.ManualFlush = true
+	if err := e.Encode("direct"); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []byte{'S', 'U', 6, 'd', 'i', 'r', 'e', 'c', 't'}; !bytes.Equal(exp, buf.Bytes()) {
+		t.Errorf("expected %v to be written without Flush but got %v", exp, buf.Bytes())
+	}
+}
+
+func TestEncoder_Reset(t *testing.T) {
+	for _, block := range []bool{false, true} {
+		var first, second bytes.Buffer
+		e := NewEncoder(&first)
+		marshal := Marshal
+		if block {
+			e = NewBlockEncoder(&first)
+			marshal = MarshalBlock
+		}
+		e.SortMapKeys = true
+		if err := e.Encode(map[string]int{"b": 2, "a": 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		var w countingWriter
+		e.Reset(&w)
+		if !e.SortMapKeys {
+			t.Error("expected options to be preserved")
+		}
+		if err := e.Encode([]interface{}{"x", map[string]int{"b": 2, "a": 1}}); err != nil {
+			t.Fatal(err)
+		}
+		e.Reset(&second)
+		if err := e.Encode(int8(-1)); err != nil {
+			t.Fatal(err)
+		}
+
+		exp, err := marshal(map[string]int{"b": 2, "a": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp, first.Bytes()) {
+			t.Errorf("block=%t: expected %q but got %q", block, exp, first.Bytes())
+		}
+		exp, err = marshal([]interface{}{"x", map[string]int{"b": 2, "a": 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp, w.Bytes()) {
+			t.Errorf("block=%t: expected %q but got %q", block, exp, w.Bytes())
+		}
+		exp, err = marshal(int8(-1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp, second.Bytes()) {
+			t.Errorf("block=%t: expected %q but got %q", block, exp, second.Bytes())
+		}
+	}
+}
+
+func TestEncoder_containers_allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	encodeArray := func(e *Encoder) error {
+		a, err := e.ArrayLen(2)
+		if err != nil {
+			return err
+		}
+		if err := a.EncodeInt8(1); err != nil {
+			return err
+		}
+		if err := a.EncodeInt8(2); err != nil {
+			return err
+		}
+		return a.End()
+	}
+	encodeObject := func(e *Encoder) error {
+		o, err := e.Object()
+		if err != nil {
+			return err
+		}
+		if err := o.EncodeKey("a"); err != nil {
+			return err
+		}
+		if err := o.EncodeArray(encodeArray); err != nil {
+			return err
+		}
+		if err := o.EncodeKey("b"); err != nil {
+			return err
+		}
+		if err := o.EncodeArray(encodeArray); err != nil {
+			return err
+		}
+		return o.End()
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		if err := e.EncodeObject(encodeObject); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations but got %f", allocs)
+	}
+}
+
+type (
+	namedString string
+	namedBool   bool
+	namedInt    int
+	namedInt8   int8
+	namedUint16 uint16
+	namedFloat  float64
+)
+
+type namedTypes struct {
+	S  namedString
+	B  namedBool
+	I  namedInt
+	U  namedUint16
+	F  namedFloat
+	I8 []namedInt8
+}
+
+func TestMarshal_namedTypes(t *testing.T) {
+	v := namedTypes{S: "s", B: true, I: -300, U: 300, F: 1.5, I8: []namedInt8{1, -1}}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := Marshal(struct {
+		S  string
+		B  bool
+		I  int
+		U  uint16
+		F  float64
+		I8 []int8
+	}{"s", true, -300, 300, 1.5, []int8{1, -1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exp, b) {
+		t.Errorf("expected %q but got %q", exp, b)
+	}
+}
+
+// ptrText implements encoding.TextMarshaler and encoding.TextUnmarshaler only
+// via pointer receivers.
+type ptrText int
+
+func (p *ptrText) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(int(*p))), nil
+}
+
+func (p *ptrText) UnmarshalText(b []byte) error {
+	i, err := strconv.Atoi(string(b))
+	*p = ptrText(i)
+	return err
+}
+
+// ptrValue implements Value only via pointer receivers, with a marker which
+// differs from its kind.
+type ptrValue struct{ A int8 }
+
+func (p *ptrValue) UBJSONType() Marker { return Int8Marker }
+
+func (p *ptrValue) MarshalUBJSON(e *Encoder) error { return e.writeInt8(p.A) }
+
+func (p *ptrValue) UnmarshalUBJSON(d *Decoder) error {
+	var err error
+	p.A, err = d.readInt8()
+	return err
+}
+
+func TestMarshal_ptrMethods(t *testing.T) {
+	type s struct{ P ptrText }
+	v := s{P: 7}
+
+	// Addressable via the pointer.
+	b, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []byte("{U\x01PSU\x017}"); !bytes.Equal(exp, b) {
+		t.Errorf("expected %q but got %q", exp, b)
+	}
+
+	// Not addressable, so a copy is used.
+	b, err = Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []byte("{U\x01PSU\x017}"); !bytes.Equal(exp, b) {
+		t.Errorf("expected %q but got %q", exp, b)
+	}
+
+	for name, tc := range map[string]struct {
+		v   interface{}
+		exp string
+	}{
+		"slice":       {[]ptrText{1, 2}, "[#U\x02SU\x011SU\x012"},
+		"array":       {[2]ptrText{1, 2}, "[#U\x02SU\x011SU\x012"},
+		"map":         {map[string]ptrText{"a": 1}, "{#U\x01U\x01aSU\x011"},
+		"value slice": {[]ptrValue{{1}, {2}}, "[#U\x02i\x01i\x02"},
+		"value array": {[2]ptrValue{{1}, {2}}, "[#U\x02i\x01i\x02"},
+		"value map":   {map[string]ptrValue{"a": {1}}, "{#U\x01U\x01ai\x01"},
+	} {
+		b, err := Marshal(tc.v)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if string(b) != tc.exp {
+			t.Errorf("%s: expected %q but got %q", name, tc.exp, b)
+		}
+		got := reflect.New(reflect.TypeOf(tc.v))
+		if err := Unmarshal(b, got.Interface()); err != nil {
+			t.Errorf("%s: failed to unmarshal: %v", name, err)
+		} else if !reflect.DeepEqual(tc.v, got.Elem().Interface()) {
+			t.Errorf("%s: expected %v but got %v", name, tc.v, got.Elem())
+		}
+	}
+}
+
+func TestMarshal_nilValue(t *testing.T) {
+	b, err := Marshal(struct{ V *benchValue }{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []byte("{U\x01VZ}"); !bytes.Equal(exp, b) {
+		t.Errorf("expected %q but got %q", exp, b)
+	}
+}
+
+type recursive struct {
+	Val      int
+	Next     *recursive `ubjson:",omitempty"`
+	Children []recursive
+}
+
+func TestMarshal_recursiveType(t *testing.T) {
+	v := recursive{
+		Val:      1,
+		Next:     &recursive{Val: 2, Children: []recursive{}},
+		Children: []recursive{{Val: 3, Children: []recursive{}}},
+	}
+	b, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got recursive
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, got) {
+		t.Errorf("expected %+v but got %+v", v, got)
+	}
+}
+
+func TestEncoder_Encode_allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	v := struct {
+		A  int
+		B  string
+		C  *bool
+		D  [2]interface{}
+		E  []string
+		F  struct{ G int8 }
+		TM time.Duration `ubjson:",nanos"`
+	}{1, "b", new(bool), [2]interface{}{1, "d"}, []string{"e"}, struct{ G int8 }{-1}, time.Second}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		if err := e.Encode(&v); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations but got %f", allocs)
+	}
+}
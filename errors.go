@@ -1,6 +1,13 @@
 package ubjson
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
 
 func errTooMany(len int) error {
 	return fmt.Errorf("too many calls for container with len %d", len)
@@ -10,6 +17,304 @@ func errWrongTypeWrite(exp, got Marker) error {
 	return fmt.Errorf("unable to write element type '%s' to container type '%s'", got, exp)
 }
 
-func errWrongTypeRead(exp, got Marker) error {
-	return fmt.Errorf("tried to read type '%s' but found type '%s'", exp, got)
+func errWrongTypeRead(exp, got Marker, t reflect.Type) error {
+	return &UnmarshalTypeError{Marker: got, Type: t, msg: fmt.Sprintf("tried to read type '%s' but found type '%s'", exp, got)}
+}
+
+func errOverflow(v interface{}, typ string) error {
+	return fmt.Errorf("value %v overflows %s", v, typ)
+}
+
+// errOverflowRead returns an *UnmarshalTypeError for a value v of type m, which
+// overflows t.
+func errOverflowRead(m Marker, v interface{}, t reflect.Type) error {
+	return &UnmarshalTypeError{Marker: m, Type: t, msg: fmt.Sprintf("value %v overflows %s", v, t)}
+}
+
+func errMaxDepth(max int) error {
+	return &LimitError{Limit: "MaxDepth", Max: int64(max), Err: fmt.Errorf("exceeded max nesting depth of %d", max)}
+}
+
+func errMaxAlloc(max, l int) error {
+	return &LimitError{Limit: "MaxCollectionAlloc", Max: int64(max), Err: fmt.Errorf("collection exceeds max allocation limit of %d: %d", max, l)}
+}
+
+func errSyntax(format string, a ...interface{}) error {
+	return &SyntaxError{Err: fmt.Errorf(format, a...)}
+}
+
+// A SyntaxError describes input which is not valid UBJSON, including input
+// which ends unexpectedly.
+type SyntaxError struct {
+	// Number of input bytes read before the error.
+	Offset int64
+	// JSON pointer to the value being decoded, such as "/items/3/name", or
+	// "" at the top level. Skipped values are not traversed.
+	Path string
+	// Underlying error.
+	Err error
+
+	located bool
+}
+
+func (e *SyntaxError) Error() string { return e.Err.Error() }
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+func (e *SyntaxError) locate(d *Decoder) {
+	if !e.located {
+		e.Offset, e.Path, e.located = d.offset(), d.path(), true
+	}
+}
+
+// An UnmarshalTypeError describes a value which is not appropriate for the Go
+// type being decoded into.
+type UnmarshalTypeError struct {
+	// Type marker of the value.
+	Marker Marker
+	// Go type being decoded into, or nil if unknown.
+	Type reflect.Type
+	// Number of input bytes read before the error.
+	Offset int64
+	// JSON pointer to the value.
+	Path string
+
+	msg     string
+	located bool
+}
+
+func (e *UnmarshalTypeError) Error() string { return e.msg }
+
+func (e *UnmarshalTypeError) locate(d *Decoder) {
+	if !e.located {
+		e.Offset, e.Path, e.located = d.offset(), d.path(), true
+	}
+}
+
+// A LimitError is returned when decoding would exceed one of the limits of a
+// Decoder. Exceeding MaxBytes, MaxStringBytes, or MaxElements wraps a
+// *BytesLimitError, *StringBytesLimitError, or *ElementsLimitError
+// respectively.
+type LimitError struct {
+	// Name of the Decoder field, such as "MaxDepth".
+	Limit string
+	// Value of the limit.
+	Max int64
+	// Number of input bytes read before the error.
+	Offset int64
+	// JSON pointer to the value being decoded.
+	Path string
+	// Underlying error.
+	Err error
+
+	located bool
+}
+
+func (e *LimitError) Error() string { return e.Err.Error() }
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+func (e *LimitError) locate(d *Decoder) {
+	if !e.located {
+		e.Offset, e.Path, e.located = d.offset(), d.path(), true
+	}
+}
+
+// An UnsupportedTypeError is returned when encoding or decoding a Go type
+// which has no UBJSON representation.
+type UnsupportedTypeError struct {
+	// Go type being encoded or decoded.
+	Type reflect.Type
+	// Number of input bytes read before the error, when decoding.
+	Offset int64
+	// JSON pointer to the value.
+	Path string
+
+	msg     string
+	located bool
+}
+
+func (e *UnsupportedTypeError) Error() string { return e.msg }
+
+func (e *UnsupportedTypeError) locate(d *Decoder) {
+	if !e.located {
+		e.Offset, e.Path, e.located = d.offset(), d.path(), true
+	}
+}
+
+// An UnknownFieldError is returned when Decoder.DisallowUnknownFields is set,
+// and an object key does not match any field of the struct being decoded into.
+type UnknownFieldError struct {
+	// Object key.
+	Key string
+	// Struct type being decoded into.
+	Type reflect.Type
+	// Number of input bytes read before the error.
+	Offset int64
+	// JSON pointer to the object.
+	Path string
+
+	located bool
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q for type %s", e.Key, e.Type)
+}
+
+func (e *UnknownFieldError) locate(d *Decoder) {
+	if !e.located {
+		e.Offset, e.Path, e.located = d.offset(), d.path(), true
+	}
+}
+
+// prependPath prepends the key or index seg to the Path of an
+// *UnsupportedTypeError in the chain of err, as encoding returns from nested
+// values.
+func prependPath(err error, seg string) {
+	var u *UnsupportedTypeError
+	if errors.As(err, &u) {
+		u.Path = "/" + pathEscaper.Replace(seg) + u.Path
+	}
+}
+
+// A locatable error has the Offset and Path of a Decoder.
+type locatable interface {
+	error
+	locate(d *Decoder)
+}
+
+// errEndOfInput is returned by readTopMarker and peekTopMarker when the input
+// ends cleanly before a top-level value. It is located as io.EOF itself.
+var errEndOfInput error = endOfInput{}
+
+type endOfInput struct{}
+
+func (endOfInput) Error() string { return io.EOF.Error() }
+
+func (endOfInput) Is(target error) bool { return target == io.EOF }
+
+// locate sets the Offset and Path of any errors in the chain of err which have
+// not already been located. Unexpected ends of input are wrapped in a located
+// *SyntaxError, while a clean end before a top-level value is returned as
+// io.EOF itself. Any other io.EOF, such as from an unmarshaler, is unexpected,
+// and is kept in the chain of the *SyntaxError.
+func (d *Decoder) locate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, errEndOfInput) {
+		return io.EOF
+	}
+	found := false
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if l, ok := e.(locatable); ok {
+			l.locate(d)
+			found = true
+		}
+	}
+	if !found {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return &SyntaxError{Offset: d.offset(), Path: d.path(), Err: err, located: true}
+		}
+		if errors.Is(err, io.EOF) {
+			return &SyntaxError{Offset: d.offset(), Path: d.path(), Err: fmt.Errorf("%w: %w", io.ErrUnexpectedEOF, err), located: true}
+		}
+	}
+	return err
+}
+
+// requireValue returns a located *SyntaxError in place of io.EOF, for inputs
+// which must not be empty.
+func requireValue(err error) error {
+	if err == io.EOF {
+		return &SyntaxError{Err: io.ErrUnexpectedEOF, located: true}
+	}
+	return err
+}
+
+// offset returns the number of input bytes consumed.
+func (d *Decoder) offset() int64 {
+	switch r := d.reader.(type) {
+	case *bytesReader:
+		return int64(r.off)
+	case *binaryReader:
+		return d.usage.bytes - int64(r.Buffered())
+	case *blockReader:
+		return d.usage.bytes - int64(r.Buffered())
+	}
+	return d.usage.bytes
+}
+
+var pathEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// path returns a JSON pointer to the value being decoded by d, from the
+// containers opened by it and its parents.
+func (d *Decoder) path() string {
+	var segs []string
+	for c := d; c != nil; c = c.parent {
+		for i := len(c.tokens) - 1; i >= 0; i-- {
+			if s, ok := c.tokens[i].segment(); ok {
+				segs = append(segs, s)
+			}
+		}
+		if c.container != nil {
+			if s, ok := c.container.segment(); ok {
+				segs = append(segs, s)
+			}
+		}
+	}
+	var b strings.Builder
+	for i := len(segs) - 1; i >= 0; i-- {
+		b.WriteByte('/')
+		pathEscaper.WriteString(&b, segs[i])
+	}
+	return b.String()
+}
+
+// A container is an ObjectDecoder or ArrayDecoder, which contributes the key
+// or index of its current value to the path of errors.
+type container interface {
+	segment() (string, bool)
+}
+
+// segment returns the key or index of the current value of f, or false if
+// none.
+func (f *tokenFrame) segment() (string, bool) {
+	if f.object {
+		return f.key, f.keyed
+	}
+	if f.count == 0 {
+		return "", false
+	}
+	return strconv.Itoa(f.count - 1), true
+}
+
+// A BytesLimitError is returned, wrapped by a *LimitError, when decoding would
+// read more than Decoder.MaxBytes from the input.
+type BytesLimitError struct {
+	Limit int64
+}
+
+func (e *BytesLimitError) Error() string {
+	return fmt.Sprintf("exceeded max input bytes limit of %d", e.Limit)
+}
+
+// A StringBytesLimitError is returned, wrapped by a *LimitError, when decoding
+// a string would exceed Decoder.MaxStringBytes in total.
+type StringBytesLimitError struct {
+	Limit int64
+}
+
+func (e *StringBytesLimitError) Error() string {
+	return fmt.Sprintf("exceeded max total string bytes limit of %d", e.Limit)
+}
+
+// An ElementsLimitError is returned, wrapped by a *LimitError, when decoding
+// an array element or object entry would exceed Decoder.MaxElements in total.
+type ElementsLimitError struct {
+	Limit int64
+}
+
+func (e *ElementsLimitError) Error() string {
+	return fmt.Sprintf("exceeded max total elements limit of %d", e.Limit)
 }
@@ -1,7 +1,10 @@
 package ubjson
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -37,27 +40,313 @@ func cachedTypeFields(t reflect.Type) fields {
 	return f
 }
 
-// Indexes fields by 'ubjson' struct tag if present, otherwise name.
+// typeFields returns the fields to encode for type t, indexed by 'ubjson'
+// struct tag if present, otherwise name. Fields tagged "-" are skipped. The
+// fields of embedded structs are promoted following Go's visibility rules, as
+// amended by the tags: the shallowest field wins, then a tagged field, and
+// otherwise ambiguous fields are dropped.
+// Based on 'encoding/json/encode.go'.
 func typeFields(t reflect.Type) fields {
-	fs := fields{
-		indexByName: make(map[string]int),
-	}
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		if f.PkgPath == "" {
-			name := f.Name
-			// Check for 'ubjson' struct tag.
-			if v, ok := f.Tag.Lookup("ubjson"); ok {
-				name = v
+	// Anonymous fields to explore at the current level and the next.
+	var current []field
+	next := []field{{typ: t}}
+
+	// Count of queued names for current level and the next.
+	var count, nextCount map[reflect.Type]int
+
+	// Types already visited at an earlier level.
+	visited := map[reflect.Type]bool{}
+
+	var list []field
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					t := sf.Type
+					if t.Kind() == reflect.Ptr {
+						t = t.Elem()
+					}
+					if sf.PkgPath != "" && t.Kind() != reflect.Struct {
+						// Ignore embedded fields of unexported non-struct types.
+						continue
+					}
+					// Do not ignore embedded fields of unexported struct types
+					// since they may have exported fields.
+				} else if sf.PkgPath != "" {
+					// Ignore unexported non-embedded fields.
+					continue
+				}
+				tag := sf.Tag.Get("ubjson")
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					// Follow pointer.
+					ft = ft.Elem()
+				}
+
+				// Record found field and index sequence.
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					fld := field{
+						name:      name,
+						tag:       tagged,
+						index:     index,
+						typ:       ft,
+						omitEmpty: opts.contains("omitempty"),
+					}
+					for tf, opt := range timeFormatOptions {
+						if opts.contains(opt) {
+							fld.timeFormat, fld.hasTimeFormat = TimeFormat(tf), true
+						}
+					}
+					if opts.contains("string") {
+						switch ft.Kind() {
+						case reflect.Bool,
+							reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64:
+							fld.asString = true
+						}
+					}
+					list = append(list, fld)
+					if count[f.typ] > 1 {
+						// If there were multiple instances, add a second,
+						// so that the annihilation code will see a duplicate.
+						list = append(list, list[len(list)-1])
+					}
+					continue
+				}
+
+				// Record new anonymous struct to explore in next round.
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		x := list
+		// Sort field by name, breaking ties with depth, then breaking ties
+		// with "name came from ubjson tag", then breaking ties with index
+		// sequence.
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tag != x[j].tag {
+			return x[i].tag
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	// Delete all fields that are hidden by the Go rules for embedded fields,
+	// except that fields with ubjson tags are promoted.
+	out := list[:0]
+	for advance, i := 0, 0; i < len(list); i += advance {
+		// One iteration per name.
+		// Find the sequence of fields with the name of this first field.
+		fi := list[i]
+		name := fi.name
+		for advance = 1; i+advance < len(list); advance++ {
+			fj := list[i+advance]
+			if fj.name != name {
+				break
 			}
-			fs.names = append(fs.names, name)
-			fs.indexByName[name] = i
 		}
+		if advance == 1 { // Only one field with this name
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(list[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+	list = out
+
+	sort.Slice(list, func(i, j int) bool {
+		return indexLess(list[i].index, list[j].index)
+	})
+
+	fs := fields{
+		list:        list,
+		indexByName: make(map[string]int, len(list)),
+	}
+	for i, f := range list {
+		fs.indexByName[f.name] = i
 	}
 	return fs
 }
 
+// indexLess reports whether index sequence a sorts before b.
+func indexLess(a, b []int) bool {
+	for k, ak := range a {
+		if k >= len(b) {
+			return false
+		}
+		if ak != b[k] {
+			return ak < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// dominantField looks through the fields, all of which are known to have the
+// same name, to find the single field that dominates the others using Go's
+// embedding rules, modified by the presence of ubjson tags. If there are
+// multiple top-level fields, the boolean will be false: This condition is an
+// error in Go and we skip all the fields.
+// Based on 'encoding/json/encode.go'.
+func dominantField(fs []field) (field, bool) {
+	// The fields are sorted in increasing index-length order, then by presence
+	// of tag. That means that the first field is the dominant one. We need
+	// only check for error cases: two fields at top level, either both tagged
+	// or neither tagged.
+	if len(fs) > 1 && len(fs[0].index) == len(fs[1].index) && fs[0].tag == fs[1].tag {
+		return field{}, false
+	}
+	return fs[0], true
+}
+
 type fields struct {
-	names       []string
+	list        []field
 	indexByName map[string]int
 }
+
+// A field describes an encodable struct field.
+type field struct {
+	// Name, from the 'ubjson' struct tag if present.
+	name string
+	// Whether the name came from the 'ubjson' struct tag.
+	tag bool
+	// Index sequence of the field, through any embedded structs.
+	index []int
+	// Type of the field, or of the pointed to struct for embedded pointers.
+	typ reflect.Type
+	// Omit the field when encoding if it has an empty value.
+	omitEmpty bool
+	// Encode the scalar value as a string.
+	asString bool
+	// Overrides the TimeFormat of time.Time and time.Duration values.
+	timeFormat    TimeFormat
+	hasTimeFormat bool
+}
+
+// fieldByIndex returns the field of structValue with index sequence index,
+// or false if it is unreachable through a nil embedded pointer.
+func fieldByIndex(structValue reflect.Value, index []int) (reflect.Value, bool) {
+	v := structValue
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// typeByIndex returns the type of the field with index sequence index, through
+// any embedded struct pointers.
+// Based on 'encoding/json/encode.go'.
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+// fieldByIndexAlloc is like fieldByIndex, but allocates nil embedded
+// pointers. Returns an error if a nil pointer to an unexported embedded struct
+// cannot be set.
+func fieldByIndexAlloc(structValue reflect.Value, index []int) (reflect.Value, error) {
+	v := structValue
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct: %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// tagOptions is the string following a comma in a struct field's 'ubjson' tag,
+// or the empty string.
+// Based on 'encoding/json/tags.go'.
+type tagOptions string
+
+// parseTag splits a struct field's 'ubjson' tag into its name and
+// comma-separated options.
+func parseTag(tag string) (string, tagOptions) {
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, tagOptions(opts)
+}
+
+// contains reports whether a comma-separated list of options contains a
+// particular option.
+func (o tagOptions) contains(option string) bool {
+	s := string(o)
+	for s != "" {
+		var name string
+		name, s, _ = strings.Cut(s, ",")
+		if name == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether v is the zero value for the purposes of the
+// 'omitempty' option.
+// Based on 'encoding/json/encode.go'.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package ubjson
+
+import (
+	"reflect"
+	"testing"
+)
+
+type embedA struct {
+	X int
+	Y int
+}
+
+type embedB struct {
+	X int
+	Z int `ubjson:"Y"`
+}
+
+type embedC struct {
+	embedA
+	W int
+}
+
+func Test_typeFields(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		v     interface{}
+		names []string
+	}{
+		{"ambiguous-dropped-tagged-wins", struct {
+			embedA
+			embedB
+		}{}, []string{"Y"}},
+		{"shallowest-wins", struct {
+			embedC
+			X string
+		}{}, []string{"Y", "W", "X"}},
+		{"tagged-embedded-not-promoted", struct {
+			embedA `ubjson:"a"`
+		}{}, []string{"a"}},
+		{"embedded-pointer", struct {
+			*embedA
+			V int
+		}{}, []string{"X", "Y", "V"}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, f := range typeFields(reflect.TypeOf(tt.v)).list {
+				names = append(names, f.name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("expected %v but got %v", tt.names, names)
+			}
+		})
+	}
+}
+
+func TestMarshalNilEmbeddedPointer(t *testing.T) {
+	v := struct {
+		*EmbeddedHeader
+		Name string
+	}{Name: "a"}
+	b, err := MarshalBlock(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const exp = "[{]\n\t[U][4][Name][S][U][1][a]\n[}]"
+	if string(b) != exp {
+		t.Errorf("expected %q but got %q", exp, string(b))
+	}
+}
+
+func TestUnmarshalEmbeddedPointer(t *testing.T) {
+	bin := []byte{'{', 'U', 0x07, 'V', 'e', 'r', 's', 'i', 'o', 'n', 'i', 0x02, '}'}
+
+	var got struct{ *EmbeddedHeader }
+	if err := Unmarshal(bin, &got); err != nil {
+		t.Fatal(err)
+	} else if got.EmbeddedHeader == nil || got.Version != 2 {
+		t.Errorf("expected version 2 but got: %+v", got.EmbeddedHeader)
+	}
+
+	var unexported struct{ *embedA }
+	bin = []byte{'{', 'U', 0x01, 'X', 'i', 0x02, '}'}
+	if err := Unmarshal(bin, &unexported); err == nil {
+		t.Error("expected error setting unexported embedded pointer")
+	}
+}
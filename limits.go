@@ -0,0 +1,71 @@
+package ubjson
+
+import "io"
+
+// A usage tracks the resources consumed by a Decoder and its container
+// decoders, toward their cumulative limits.
+type usage struct {
+	// Bytes read from the input.
+	bytes int64
+	// Length of decoded strings.
+	stringBytes int64
+	// Array elements and object entries.
+	elements int64
+}
+
+// A limitedReader counts the bytes read from r, and returns a
+// *LimitError rather than read more than d.MaxBytes. Reads are shortened
+// to the remaining limit, so buffering never reads beyond it.
+type limitedReader struct {
+	r io.Reader
+	d *Decoder
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	u := l.d.usage
+	if max := l.d.MaxBytes; max > 0 {
+		rem := max - u.bytes
+		if rem <= 0 {
+			return 0, &LimitError{Limit: "MaxBytes", Max: max, Err: &BytesLimitError{Limit: max}}
+		}
+		if int64(len(p)) > rem {
+			p = p[:rem]
+		}
+	}
+	n, err := l.r.Read(p)
+	u.bytes += int64(n)
+	return n, err
+}
+
+// The readString method reads a string of at most max bytes, and accounts for
+// its length toward MaxStringBytes before allocating it.
+func (d *Decoder) readString(max int) (string, error) {
+	l, err := readStringLen(d.reader, max)
+	if err != nil {
+		return "", err
+	}
+	if err := d.addStringBytes(l); err != nil {
+		return "", err
+	}
+	return d.readStringData(l)
+}
+
+// The addStringBytes method accounts for a string of length l toward
+// MaxStringBytes.
+func (d *Decoder) addStringBytes(l int) error {
+	d.usage.stringBytes += int64(l)
+	if d.MaxStringBytes > 0 && d.usage.stringBytes > d.MaxStringBytes {
+		return &LimitError{Limit: "MaxStringBytes", Max: d.MaxStringBytes, Err: &StringBytesLimitError{Limit: d.MaxStringBytes}}
+	}
+	return nil
+}
+
+// The addElements method accounts for n array elements or object entries
+// toward MaxElements.
+func (d *Decoder) addElements(n int) error {
+	d.usage.elements += int64(n)
+	if d.MaxElements > 0 && d.usage.elements > d.MaxElements {
+		return &LimitError{Limit: "MaxElements", Max: d.MaxElements, Err: &ElementsLimitError{Limit: d.MaxElements}}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build !race
+// +build !race
+
+package ubjson
+
+// raceEnabled is true when the race detector is on, which adds allocations.
+const raceEnabled = false
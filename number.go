@@ -0,0 +1,175 @@
+package ubjson
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// A Number is a numeric value of any type (U,i,I,l,L,d,D,H), which preserves
+// its original type marker and value. Decoders produce Numbers in place of
+// the individual numeric types when decoding into interface{} with UseNumber
+// set, and Encoders write them back with the same type marker. The zero
+// value is the integer 0 (U).
+type Number struct {
+	marker Marker
+	i      int64   // U,i,I,l,L
+	f      float64 // d,D
+	h      string  // H
+}
+
+// Marker returns the type marker of n.
+func (n Number) Marker() Marker {
+	if n.marker == 0 {
+		return UInt8Marker
+	}
+	return n.marker
+}
+
+// Int64 returns n as an int64, or an error if n is not an integer or does
+// not fit.
+func (n Number) Int64() (int64, error) {
+	switch n.Marker() {
+	case Float32Marker, Float64Marker:
+		if n.f != math.Trunc(n.f) || n.f < math.MinInt64 || n.f >= math.MaxInt64 {
+			return 0, fmt.Errorf("number %s is not an int64", n)
+		}
+		return int64(n.f), nil
+	case HighPrecNumMarker:
+		i, err := HighPrecNumber(n.h).BigInt()
+		if err != nil {
+			return 0, err
+		}
+		if !i.IsInt64() {
+			return 0, errOverflow(n.h, "int64")
+		}
+		return i.Int64(), nil
+	}
+	return n.i, nil
+}
+
+// Uint64 returns n as a uint64, or an error if n is not an integer or does
+// not fit.
+func (n Number) Uint64() (uint64, error) {
+	switch n.Marker() {
+	case Float32Marker, Float64Marker:
+		if n.f != math.Trunc(n.f) || n.f < 0 || n.f >= math.MaxUint64 {
+			return 0, fmt.Errorf("number %s is not a uint64", n)
+		}
+		return uint64(n.f), nil
+	case HighPrecNumMarker:
+		i, err := HighPrecNumber(n.h).BigInt()
+		if err != nil {
+			return 0, err
+		}
+		if !i.IsUint64() {
+			return 0, errOverflow(n.h, "uint64")
+		}
+		return i.Uint64(), nil
+	}
+	if n.i < 0 {
+		return 0, errOverflow(n.i, "uint64")
+	}
+	return uint64(n.i), nil
+}
+
+// Float64 returns n as a float64. Large integers and high precision numbers
+// may lose precision.
+func (n Number) Float64() (float64, error) {
+	switch n.Marker() {
+	case Float32Marker, Float64Marker:
+		return n.f, nil
+	case HighPrecNumMarker:
+		if !validNumber(n.h) {
+			return 0, fmt.Errorf("invalid high precision number: %q", n.h)
+		}
+		return strconv.ParseFloat(n.h, 64)
+	}
+	return float64(n.i), nil
+}
+
+// BigInt returns n as a big.Int, or an error if n is not an integer.
+func (n Number) BigInt() (*big.Int, error) {
+	switch n.Marker() {
+	case Float32Marker, Float64Marker:
+		if math.IsInf(n.f, 0) || math.IsNaN(n.f) || n.f != math.Trunc(n.f) {
+			return nil, fmt.Errorf("number %s is not an integer", n)
+		}
+		i, _ := big.NewFloat(n.f).Int(nil)
+		return i, nil
+	case HighPrecNumMarker:
+		return HighPrecNumber(n.h).BigInt()
+	}
+	return big.NewInt(n.i), nil
+}
+
+// String returns the decimal representation of n.
+func (n Number) String() string {
+	switch n.Marker() {
+	case Float32Marker:
+		return strconv.FormatFloat(n.f, 'g', -1, 32)
+	case Float64Marker:
+		return strconv.FormatFloat(n.f, 'g', -1, 64)
+	case HighPrecNumMarker:
+		return n.h
+	}
+	return strconv.FormatInt(n.i, 10)
+}
+
+// EncodeNumber encodes a Number with its original type marker.
+func (e *Encoder) EncodeNumber(n Number) error {
+	switch n.Marker() {
+	case UInt8Marker:
+		return e.EncodeUInt8(uint8(n.i))
+	case Int8Marker:
+		return e.EncodeInt8(int8(n.i))
+	case Int16Marker:
+		return e.EncodeInt16(int16(n.i))
+	case Int32Marker:
+		return e.EncodeInt32(int32(n.i))
+	case Int64Marker:
+		return e.EncodeInt64(n.i)
+	case Float32Marker:
+		return e.EncodeFloat32(float32(n.f))
+	case Float64Marker:
+		return e.EncodeFloat64(n.f)
+	case HighPrecNumMarker:
+		return e.EncodeHighPrecNum(n.h)
+	}
+	return fmt.Errorf("unable to encode number with type marker: %s", n.marker)
+}
+
+// DecodeNumber decodes any numeric value (U,i,I,l,L,d,D,H) into a Number.
+func (d *Decoder) DecodeNumber() (Number, error) {
+	m, err := d.readValType()
+	if err != nil {
+		return Number{}, d.locate(err)
+	}
+	n, err := d.readNumber(m)
+	return n, d.locate(err)
+}
+
+// readNumber reads the data of a numeric value with type marker m.
+func (d *Decoder) readNumber(m Marker) (Number, error) {
+	n := Number{marker: m}
+	var err error
+	switch m {
+	case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+		n.i, err = readIntData(d, m)
+	case Float32Marker:
+		var f float32
+		f, err = d.readFloat32()
+		n.f = float64(f)
+	case Float64Marker:
+		n.f, err = d.readFloat64()
+	case HighPrecNumMarker:
+		n.h, err = d.readString(d.MaxCollectionAlloc)
+	default:
+		return Number{}, &UnmarshalTypeError{Marker: m, Type: numberType, msg: fmt.Sprintf("unable to decode number from type marker: %s", m)}
+	}
+	if err != nil {
+		return Number{}, err
+	}
+	return n, nil
+}
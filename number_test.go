@@ -0,0 +1,151 @@
+package ubjson
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDecoder_UseNumber(t *testing.T) {
+	in := []interface{}{
+		uint8(1), int8(-2), int16(300), int32(70000), int64(math.MaxInt64),
+		float32(1.5), float64(-0.25), HighPrecNumber("18446744073709551616"),
+		"s", map[string]interface{}{"n": int8(-1)},
+	}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var plain interface{}
+	if err := Unmarshal(b, &plain); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, plain) {
+		t.Errorf("expected native types by default:\n%#v\nbut got:\n%#v", in, plain)
+	}
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.UseNumber = true
+	var got interface{}
+	if err := d.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	vals, ok := got.([]interface{})
+	if !ok || len(vals) != len(in) {
+		t.Fatalf("unexpected result: %#v", got)
+	}
+	for i, exp := range []struct {
+		marker Marker
+		str    string
+	}{
+		{UInt8Marker, "1"},
+		{Int8Marker, "-2"},
+		{Int16Marker, "300"},
+		{Int32Marker, "70000"},
+		{Int64Marker, "9223372036854775807"},
+		{Float32Marker, "1.5"},
+		{Float64Marker, "-0.25"},
+		{HighPrecNumMarker, "18446744073709551616"},
+	} {
+		n, ok := vals[i].(Number)
+		if !ok {
+			t.Errorf("%d: expected Number but got %T", i, vals[i])
+			continue
+		}
+		if n.Marker() != exp.marker {
+			t.Errorf("%d: expected marker %s but got %s", i, exp.marker, n.Marker())
+		}
+		if n.String() != exp.str {
+			t.Errorf("%d: expected %s but got %s", i, exp.str, n)
+		}
+	}
+	if s, ok := vals[8].(string); !ok || s != "s" {
+		t.Errorf("expected string but got %#v", vals[8])
+	}
+	if m, ok := vals[9].(map[string]interface{}); !ok {
+		t.Errorf("expected map but got %#v", vals[9])
+	} else if _, ok := m["n"].(Number); !ok {
+		t.Errorf("expected nested Number but got %T", m["n"])
+	}
+
+	// Numbers are encoded with their original markers.
+	out, err := Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, out) {
+		t.Errorf("expected re-encoding:\n%v\nbut got:\n%v", b, out)
+	}
+}
+
+func TestNumber(t *testing.T) {
+	big := Number{marker: HighPrecNumMarker, h: "18446744073709551616"}
+	if _, err := big.Int64(); err == nil {
+		t.Error("expected int64 overflow")
+	}
+	if _, err := big.Uint64(); err == nil {
+		t.Error("expected uint64 overflow")
+	}
+	if f, err := big.Float64(); err != nil || f != 1<<64 {
+		t.Errorf("unexpected float64 %v: %v", f, err)
+	}
+	if i, err := big.BigInt(); err != nil || i.String() != big.h {
+		t.Errorf("unexpected big.Int %v: %v", i, err)
+	}
+
+	neg := Number{marker: Int8Marker, i: -1}
+	if _, err := neg.Uint64(); err == nil {
+		t.Error("expected error for negative uint64")
+	}
+	if i, err := neg.Int64(); err != nil || i != -1 {
+		t.Errorf("unexpected int64 %d: %v", i, err)
+	}
+
+	frac := Number{marker: Float64Marker, f: 1.5}
+	if _, err := frac.Int64(); err == nil {
+		t.Error("expected error for non-integer int64")
+	}
+	if _, err := frac.BigInt(); err == nil {
+		t.Error("expected error for non-integer big.Int")
+	}
+	whole := Number{marker: Float32Marker, f: 3}
+	if u, err := whole.Uint64(); err != nil || u != 3 {
+		t.Errorf("unexpected uint64 %d: %v", u, err)
+	}
+
+	var zero Number
+	if zero.Marker() != UInt8Marker || zero.String() != "0" {
+		t.Errorf("unexpected zero value %s %s", zero.Marker(), zero)
+	}
+	if b, err := Marshal(zero); err != nil || !bytes.Equal(b, []byte{'U', 0}) {
+		t.Errorf("unexpected zero value encoding %v: %v", b, err)
+	}
+}
+
+func TestDecoder_UseNumber_typedContainers(t *testing.T) {
+	for name, tc := range map[string]struct {
+		b   string
+		exp interface{}
+	}{
+		"array": {"[$i#U\x02\x01\xff", []interface{}{
+			Number{marker: Int8Marker, i: 1}, Number{marker: Int8Marker, i: -1},
+		}},
+		"object": {"{$l#U\x01U\x01a\x00\x01\x11\x70", map[string]interface{}{
+			"a": Number{marker: Int32Marker, i: 70000},
+		}},
+		"float": {"[$d#U\x01\x3f\xc0\x00\x00", []interface{}{
+			Number{marker: Float32Marker, f: 1.5},
+		}},
+	} {
+		d := NewDecoder(bytes.NewReader([]byte(tc.b)))
+		d.UseNumber = true
+		var got interface{}
+		if err := d.Decode(&got); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if !reflect.DeepEqual(tc.exp, got) {
+			t.Errorf("%s: expected %#v but got %#v", name, tc.exp, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package ubjson
+
+import (
+	"bytes"
+	"sync"
+)
+
+// An encodeState is an Encoder writing to its own buffer, which is reused by
+// Marshal and MarshalBlock.
+type encodeState struct {
+	bytes.Buffer
+	e *Encoder
+}
+
+var (
+	binaryEncodeStatePool sync.Pool
+	blockEncodeStatePool  sync.Pool
+)
+
+// The getEncodeState function returns a reset encodeState from the pool for
+// binary or block-notation, or a new one if none are available.
+func getEncodeState(block bool) *encodeState {
+	pool := &binaryEncodeStatePool
+	if block {
+		pool = &blockEncodeStatePool
+	}
+	if es, ok := pool.Get().(*encodeState); ok {
+		es.Buffer.Reset()
+		es.e.Reset(&es.Buffer)
+		es.e.SortMapKeys = true
+		es.e.TimeFormat = TimeText
+		es.e.ManualFlush = false
+		return es
+	}
+	es := new(encodeState)
+	if block {
+		es.e = NewBlockEncoder(&es.Buffer)
+	} else {
+		es.e = NewEncoder(&es.Buffer)
+	}
+	es.e.SortMapKeys = true
+	return es
+}
+
+// The putEncodeState function returns es to the pool for binary or
+// block-notation.
+func putEncodeState(es *encodeState, block bool) {
+	if block {
+		blockEncodeStatePool.Put(es)
+	} else {
+		binaryEncodeStatePool.Put(es)
+	}
+}
+
+// A blockDecodeState is a block-notation Decoder reading from its own
+// bytes.Reader, which is reused by UnmarshalBlock.
+type blockDecodeState struct {
+	bytes.Reader
+	d *Decoder
+}
+
+var (
+	bytesDecoderPool     sync.Pool
+	blockDecodeStatePool sync.Pool
+)
+
+// The getBytesDecoder function returns a Decoder from the pool which reads
+// directly from b, or a new one if none are available.
+func getBytesDecoder(b []byte, noCopy bool) *Decoder {
+	d, ok := bytesDecoderPool.Get().(*Decoder)
+	if !ok {
+		return newBytesDecoder(b, noCopy)
+	}
+	r := d.reader.(*bytesReader)
+	r.b, r.off, r.noCopy = b, 0, noCopy
+	d.tokens = d.tokens[:0]
+	*d.usage = usage{}
+	resetDecoderOptions(d)
+	return d
+}
+
+// The putBytesDecoder function returns d to the pool, after releasing its
+// input.
+func putBytesDecoder(d *Decoder) {
+	d.reader.(*bytesReader).b = nil
+	bytesDecoderPool.Put(d)
+}
+
+// The getBlockDecodeState function returns a blockDecodeState from the pool
+// which reads from b, or a new one if none are available.
+func getBlockDecodeState(b []byte) *blockDecodeState {
+	ds, ok := blockDecodeStatePool.Get().(*blockDecodeState)
+	if !ok {
+		ds = new(blockDecodeState)
+		ds.Reader.Reset(b)
+		ds.d = NewBlockDecoder(&ds.Reader)
+		return ds
+	}
+	ds.Reader.Reset(b)
+	ds.d.Reset(&ds.Reader)
+	resetDecoderOptions(ds.d)
+	return ds
+}
+
+// The putBlockDecodeState function returns ds to the pool, after releasing its
+// input.
+func putBlockDecodeState(ds *blockDecodeState) {
+	ds.Reader.Reset(nil)
+	blockDecodeStatePool.Put(ds)
+}
+
+// The resetDecoderOptions function restores the default options of d, in case
+// they were modified while decoding.
+func resetDecoderOptions(d *Decoder) {
+	d.MaxCollectionAlloc = MaxCollectionAlloc
+	d.MaxDepth = MaxDepth
+	d.MaxBytes = 0
+	d.MaxStringBytes = 0
+	d.MaxElements = 0
+	d.StrictNumbers = false
+	d.TimeFormat = TimeText
+	d.DisallowUnknownFields = false
+	d.CaseInsensitiveFields = false
+	d.UseNumber = false
+}
@@ -0,0 +1,89 @@
+package ubjson
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshal_pooled(t *testing.T) {
+	first, err := Marshal("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := append([]byte(nil), first...)
+	// A failure part way through a container must not affect later calls.
+	if _, err := Marshal([]interface{}{1, make(chan int)}); err == nil {
+		t.Error("expected error for unsupported type")
+	}
+	if _, err := Marshal("second"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exp, first) {
+		t.Errorf("expected %q to be unchanged but got %q", exp, first)
+	}
+	b, err := Marshal(map[string]int{"b": 2, "a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []byte{'{', '#', 'U', 2, 'U', 1, 'a', 'U', 1, 'U', 1, 'b', 'U', 2}; !bytes.Equal(exp, b) {
+		t.Errorf("expected %q but got %q", exp, b)
+	}
+}
+
+func TestUnmarshal_pooled(t *testing.T) {
+	bin, err := Marshal(map[string]interface{}{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An Unmarshaler changing options must not affect later calls.
+	var v optionsValue
+	if err := Unmarshal(bin, &v); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := Unmarshal(bin, &m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["a"].(uint8); !ok {
+		t.Errorf("expected uint8 but got %T", m["a"])
+	}
+
+	block, err := MarshalBlock(map[string]interface{}{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalBlock(block, &v); err != nil {
+		t.Fatal(err)
+	}
+	m = nil
+	if err := UnmarshalBlock(block, &m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["a"].(uint8); !ok {
+		t.Errorf("expected uint8 but got %T", m["a"])
+	}
+}
+
+// An optionsValue sets Decoder options while decoding.
+type optionsValue struct{}
+
+func (optionsValue) UBJSONType() Marker { return ObjectStartMarker }
+
+func (optionsValue) MarshalUBJSON(*Encoder) error { return nil }
+
+func (*optionsValue) UnmarshalUBJSON(d *Decoder) error {
+	d.UseNumber = true
+	o, err := d.Object()
+	if err != nil {
+		return err
+	}
+	for o.NextEntry() {
+		if _, err := o.DecodeKey(); err != nil {
+			return err
+		}
+		if err := o.Skip(); err != nil {
+			return err
+		}
+	}
+	return o.End()
+}
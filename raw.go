@@ -0,0 +1,199 @@
+package ubjson
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A RawValue is a complete binary UBJSON value, including its type marker. It
+// may be used as a struct field or Decode target to capture a value without
+// interpreting it, or as an Encode argument to splice in a precomputed value.
+// A nil or empty RawValue is encoded as null (Z).
+type RawValue []byte
+
+// EncodeRawValue encodes a RawValue. Binary Encoders write the bytes
+// verbatim, without validation, while block Encoders write the equivalent
+// block notation.
+func (e *Encoder) EncodeRawValue(v RawValue) error {
+	if len(v) == 0 {
+		return e.EncodeNull()
+	}
+	m := Marker(v[0])
+	return e.encode(m, func(e *Encoder) error {
+		if w, ok := e.writer.(*binaryWriter); ok {
+			return w.write(v[1:])
+		}
+		d := NewDecoder(bytes.NewReader(v[1:]))
+		return skipData(&teeReader{reader: d.reader, w: e.writer, d: d}, m, 0, d)
+	})
+}
+
+// DecodeRawValue decodes the next value, including nested containers, into a
+// RawValue with the exact bytes of its binary encoding. Values from block
+// Decoders, and elements of strongly typed containers (which omit their type
+// markers), are converted to the equivalent standalone binary encoding.
+func (d *Decoder) DecodeRawValue() (RawValue, error) {
+	v, err := d.decodeRawValue()
+	return v, d.locate(err)
+}
+
+func (d *Decoder) decodeRawValue() (RawValue, error) {
+	m, err := d.readValType()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := newBinaryWriter(&buf, 0)
+	if err := w.writeMarker(m); err != nil {
+		return nil, err
+	}
+	if err := skipData(&teeReader{reader: d.reader, w: w, d: d}, m, d.containerDepth(), d); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return RawValue(buf.Bytes()), nil
+}
+
+// A teeReader is a reader which writes everything it reads to w, with the same
+// type markers. Strings are accounted toward the limits of d, and since they
+// are allocated, their lengths are limited by d.MaxCollectionAlloc.
+type teeReader struct {
+	reader
+	w writer
+	d *Decoder
+}
+
+func (t *teeReader) readMarker() (Marker, error) {
+	m, err := t.reader.readMarker()
+	if err != nil {
+		return 0, err
+	}
+	return m, t.w.writeMarker(m)
+}
+
+func (t *teeReader) readUInt8() (uint8, error) {
+	v, err := t.reader.readUInt8()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeUInt8(v)
+}
+
+func (t *teeReader) readInt8() (int8, error) {
+	v, err := t.reader.readInt8()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeInt8(v)
+}
+
+func (t *teeReader) readInt16() (int16, error) {
+	v, err := t.reader.readInt16()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeInt16(v)
+}
+
+func (t *teeReader) readInt32() (int32, error) {
+	v, err := t.reader.readInt32()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeInt32(v)
+}
+
+func (t *teeReader) readInt64() (int64, error) {
+	v, err := t.reader.readInt64()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeInt64(v)
+}
+
+func (t *teeReader) readFloat32() (float32, error) {
+	v, err := t.reader.readFloat32()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeFloat32(v)
+}
+
+func (t *teeReader) readFloat64() (float64, error) {
+	v, err := t.reader.readFloat64()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeFloat64(v)
+}
+
+// The readString method reads the length prefix through t, so that its
+// original type marker is preserved.
+func (t *teeReader) readString(max int) (string, error) {
+	l, err := readStringLen(t, max)
+	if err != nil {
+		return "", err
+	}
+	return t.readStringData(l)
+}
+
+func (t *teeReader) readStringData(l int) (string, error) {
+	if l > t.d.MaxCollectionAlloc {
+		return "", errMaxAlloc(t.d.MaxCollectionAlloc, l)
+	}
+	if err := t.d.addStringBytes(l); err != nil {
+		return "", err
+	}
+	s, err := t.reader.readStringData(l)
+	if err != nil {
+		return "", err
+	}
+	return s, t.w.writeStringData(s)
+}
+
+func (t *teeReader) readChar() (byte, error) {
+	v, err := t.reader.readChar()
+	if err != nil {
+		return 0, err
+	}
+	return v, t.w.writeChar(v)
+}
+
+// The skipFixed method reads each value through t, so that it is written.
+func (t *teeReader) skipFixed(m Marker, n int) error {
+	for i := 0; i < n; i++ {
+		var err error
+		switch m {
+		case NullMarker, TrueMarker, FalseMarker:
+			return nil
+		case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+			_, err = readIntData(t, m)
+		case Float32Marker:
+			_, err = t.readFloat32()
+		case Float64Marker:
+			_, err = t.readFloat64()
+		case CharMarker:
+			_, err = t.readChar()
+		default:
+			return fmt.Errorf("type %s is not of a fixed size", m)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *teeReader) readBulk(m Marker, b []byte) error {
+	if err := t.reader.readBulk(m, b); err != nil {
+		return err
+	}
+	return t.w.writeBulk(m, b)
+}
+
+func (t *teeReader) skipStringData(l int) error {
+	_, err := t.readStringData(l)
+	return err
+}
@@ -0,0 +1,130 @@
+package ubjson
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRawValue(t *testing.T) {
+	// An object with optimized and unoptimized containers, and a string length
+	// prefix with a wider type marker than necessary.
+	payload := []byte{'{',
+		'U', 1, 'a', '[', '$', 'i', '#', 'U', 2, 0xFF, 0x01,
+		'U', 1, 'b', '[', 'S', 'I', 0x00, 0x02, 'h', 'i', 'Z', ']',
+		'U', 1, 'c', 'H', 'U', 2, '1', '0',
+		'}'}
+	type envelope struct {
+		ID      int8
+		Payload RawValue
+		Empty   RawValue `ubjson:",omitempty"`
+	}
+	b := []byte{'{',
+		'U', 2, 'I', 'D', 'i', 7,
+		'U', 7, 'P', 'a', 'y', 'l', 'o', 'a', 'd'}
+	b = append(b, payload...)
+	b = append(b, '}')
+
+	var env envelope
+	if err := Unmarshal(b, &env); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(env.Payload, payload) {
+		t.Errorf("expected payload:\n%v\nbut got:\n%v", payload, env.Payload)
+	}
+
+	out, err := Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, b) {
+		t.Errorf("expected output:\n%v\nbut got:\n%v", b, out)
+	}
+
+	// Block notation is converted to and from binary. Note that block
+	// notation cannot represent the end of unsized arrays.
+	env.Payload = append(RawValue{}, payload[:12]...)
+	env.Payload = append(env.Payload, '}')
+	block, err := MarshalBlock(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromBlock envelope
+	if err := UnmarshalBlock(block, &fromBlock); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, fromBlock) {
+		t.Errorf("expected from block:\n%v\nbut got:\n%v", env, fromBlock)
+	}
+}
+
+func TestRawValue_typedContainer(t *testing.T) {
+	b := []byte{'[', '$', 'S', '#', 'U', 2, 'U', 1, 'a', 'U', 1, 'b'}
+	var raws []RawValue
+	if err := Unmarshal(b, &raws); err != nil {
+		t.Fatal(err)
+	}
+	exp := []RawValue{{'S', 'U', 1, 'a'}, {'S', 'U', 1, 'b'}}
+	if !reflect.DeepEqual(raws, exp) {
+		t.Errorf("expected %v but got %v", exp, raws)
+	}
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).EncodeArray(func(e *Encoder) error {
+		a, err := e.ArrayType(StringMarker, 2)
+		if err != nil {
+			return err
+		}
+		for _, r := range raws {
+			if err := a.Encode(r); err != nil {
+				return err
+			}
+		}
+		return a.End()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("expected:\n%v\nbut got:\n%v", b, buf.Bytes())
+	}
+}
+
+func TestRawValue_null(t *testing.T) {
+	b, err := Marshal(RawValue(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte{'Z'}) {
+		t.Errorf("expected null but got %v", b)
+	}
+	var r RawValue
+	if err := Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{'Z'}) {
+		t.Errorf("expected null but got %v", r)
+	}
+}
+
+func TestRawValue_hostileLength(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"string":    {'S', 'L', 0x3f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'a'},
+		"string-2G": {'S', 'l', 0x7f, 0xff, 0xff, 0xff},
+		"high-prec": {'H', 'l', 0x7f, 0xff, 0xff, 0xff},
+		"key":       {'{', 'l', 0x7f, 0xff, 0xff, 0xff},
+		"nested":    {'[', 'S', 'L', 0x3f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'a'},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var r RawValue
+			var lerr *LimitError
+			if err := Unmarshal(b, &r); !errors.As(err, &lerr) || lerr.Limit != "MaxCollectionAlloc" {
+				t.Errorf("Unmarshal: expected MaxCollectionAlloc *LimitError but got: %v", err)
+			}
+			if err := NewDecoder(bytes.NewReader(b)).Decode(&r); !errors.As(err, &lerr) || lerr.Limit != "MaxCollectionAlloc" {
+				t.Errorf("Decoder: expected MaxCollectionAlloc *LimitError but got: %v", err)
+			}
+		})
+	}
+}
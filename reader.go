@@ -3,11 +3,11 @@ package ubjson
 import (
 	"bufio"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"math"
 	"strconv"
+	"unsafe"
 )
 
 // A reader reads UBJSON types.
@@ -27,7 +27,18 @@ type reader interface {
 	readFloat64() (float64, error)
 
 	readString(max int) (string, error)
+	// Read the data of a string of length l, following the length prefix.
+	readStringData(l int) (string, error)
 	readChar() (byte, error)
+
+	// Skip the data of n consecutive values of fixed size type m.
+	skipFixed(m Marker, n int) error
+	// Skip the data of a string of length l, following the length prefix.
+	skipStringData(l int) error
+
+	// Read the data of consecutive values of fixed size type m into b, as
+	// big-endian binary.
+	readBulk(m Marker, b []byte) error
 }
 
 // The readInt function dynamically reads an integer of unspecified size.
@@ -36,24 +47,67 @@ func readInt(r reader) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	i, err := readIntData(r, m)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read int: %w", err)
+	}
+	return int(i), nil
+}
+
+// The readStringLen function reads a string length prefix, which must not be
+// negative or exceed max.
+func readStringLen(r reader, max int) (int, error) {
+	l, err := readInt(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read string length prefix: %w", err)
+	}
+	switch {
+	case l < 0:
+		return 0, errSyntax("illegal string length prefix: %d", l)
+	case l > max:
+		return 0, &LimitError{Limit: "MaxCollectionAlloc", Max: int64(max), Err: fmt.Errorf("string length prefix exceeds max allocation limit of %d: %d", max, l)}
+	}
+	return l, nil
+}
+
+// The readIntData function reads the data for an integer of type m (U,i,I,l,L),
+// widened to an int64.
+func readIntData(r reader, m Marker) (int64, error) {
 	switch m {
 	case UInt8Marker:
 		u, err := r.readUInt8()
-		return int(u), err
+		return int64(u), err
 	case Int8Marker:
 		i, err := r.readInt8()
-		return int(i), err
+		return int64(i), err
 	case Int16Marker:
 		i, err := r.readInt16()
-		return int(i), err
+		return int64(i), err
 	case Int32Marker:
 		i, err := r.readInt32()
-		return int(i), err
+		return int64(i), err
 	case Int64Marker:
-		i, err := r.readInt64()
-		return int(i), err
+		return r.readInt64()
 	}
-	return 0, fmt.Errorf("failed to read int: expected int marker but found %q", m)
+	return 0, errSyntax("expected int marker but found %q", m)
+}
+
+// The fixedSize function returns the data size in bytes of values of type m,
+// or false if values of type m are not of a fixed size.
+func fixedSize(m Marker) (int, bool) {
+	switch m {
+	case NullMarker, TrueMarker, FalseMarker:
+		return 0, true
+	case UInt8Marker, Int8Marker, CharMarker:
+		return 1, true
+	case Int16Marker:
+		return 2, true
+	case Int32Marker, Float32Marker:
+		return 4, true
+	case Int64Marker, Float64Marker:
+		return 8, true
+	}
+	return 0, false
 }
 
 // The readContainer method parses and returns a container type marker and
@@ -77,14 +131,14 @@ func readContainer(r reader) (Marker, int, error) {
 		if c, err := r.readMarker(); err != nil {
 			return 0, 0, err
 		} else if c != countMarker {
-			return 0, 0, errors.New("count marker (#) required following container type marker")
+			return 0, 0, errSyntax("count marker (#) required following container type marker")
 		}
 		l, err := readInt(r)
 		if err != nil {
 			return 0, 0, err
 		}
 		if l < 0 {
-			return 0, 0, fmt.Errorf("illegal negative container length: %d", l)
+			return 0, 0, errSyntax("illegal negative container length: %d", l)
 		}
 		return m, l, nil
 
@@ -97,7 +151,7 @@ func readContainer(r reader) (Marker, int, error) {
 			return 0, 0, err
 		}
 		if l < 0 {
-			return 0, 0, fmt.Errorf("illegal negative container length: %d", l)
+			return 0, 0, errSyntax("illegal negative container length: %d", l)
 		}
 		return 0, l, nil
 
@@ -106,6 +160,16 @@ func readContainer(r reader) (Marker, int, error) {
 	}
 }
 
+// The unexpectedEOF function returns io.ErrUnexpectedEOF in place of io.EOF.
+// Input may only end cleanly before a top-level value, which the Decoder
+// checks for before reading it.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 // A binaryReader reads binary UBJSON.
 type binaryReader struct {
 	*bufio.Reader
@@ -120,7 +184,7 @@ func newBinaryReader(r io.Reader) *binaryReader {
 func (r *binaryReader) readMarker() (Marker, error) {
 	b, err := r.ReadByte()
 	if err != nil {
-		return 0, errors.New("failed to read marker")
+		return 0, fmt.Errorf("failed to read marker: %w", unexpectedEOF(err))
 	}
 	return Marker(b), nil
 }
@@ -128,7 +192,7 @@ func (r *binaryReader) readMarker() (Marker, error) {
 func (r *binaryReader) peekMarker() (Marker, error) {
 	b, err := r.Peek(1)
 	if err != nil {
-		return 0, errors.New("failed to peek marker")
+		return 0, fmt.Errorf("failed to peek marker: %w", unexpectedEOF(err))
 	}
 	return Marker(b[0]), nil
 }
@@ -136,7 +200,7 @@ func (r *binaryReader) peekMarker() (Marker, error) {
 func (r *binaryReader) readUInt8() (uint8, error) {
 	b, err := r.ReadByte()
 	if err != nil {
-		return 0, errors.New("failed to read UInt8 byte")
+		return 0, fmt.Errorf("failed to read UInt8 byte: %w", unexpectedEOF(err))
 	}
 	return uint8(b), nil
 }
@@ -144,7 +208,7 @@ func (r *binaryReader) readUInt8() (uint8, error) {
 func (r *binaryReader) readInt8() (int8, error) {
 	b, err := r.ReadByte()
 	if err != nil {
-		return 0, errors.New("failed to read Int8 byte")
+		return 0, fmt.Errorf("failed to read Int8 byte: %w", unexpectedEOF(err))
 	}
 	return int8(b), nil
 }
@@ -152,12 +216,8 @@ func (r *binaryReader) readInt8() (int8, error) {
 // The readBuf method reads len bytes into r.buf. len must not exceed 8.
 func (r *binaryReader) readBuf(len int) ([]byte, error) {
 	b := r.buf[:len]
-	n, err := r.Read(b)
-	if err != nil {
-		return nil, err
-	}
-	if n != len {
-		return nil, errors.New("TODO failed to read enough bytes")
+	if _, err := io.ReadFull(r.Reader, b); err != nil {
+		return nil, fmt.Errorf("failed to read %d bytes: %w", len, unexpectedEOF(err))
 	}
 	return b, nil
 }
@@ -206,36 +266,73 @@ func (r *binaryReader) readFloat64() (float64, error) {
 }
 
 func (r *binaryReader) readString(max int) (string, error) {
-	l, err := readInt(r)
+	l, err := readStringLen(r, max)
 	if err != nil {
-		return "", fmt.Errorf("failed to read string length prefix: %w", err)
+		return "", err
 	}
-	switch {
-	case l == 0:
+	return r.readStringData(l)
+}
+
+func (r *binaryReader) readStringData(l int) (string, error) {
+	if l == 0 {
 		return "", nil
-	case l < 0:
-		return "", fmt.Errorf("illegal string length prefix: %d", l)
-	case l > max:
-		return "", fmt.Errorf("string length prefix exceeds max allocation limit of %d: %d", max, l)
 	}
 	b := make([]byte, l)
-	n, err := r.Read(b)
-	if err != nil {
-		return "", fmt.Errorf("failed to read string bytes: %w", err)
+	if n, err := io.ReadFull(r.Reader, b); err != nil {
+		return "", fmt.Errorf("failed to read string of length %d, only read %d: %w", l, n, unexpectedEOF(err))
 	}
-	if n != l {
-		return "", fmt.Errorf("failed to read full string length (%d), instead got: %s", l, string(b[:n]))
+	// b is not retained, so it may back the string without a copy.
+	return unsafe.String(&b[0], l), nil
+}
+
+func (r *binaryReader) skipFixed(m Marker, n int) error {
+	size, ok := fixedSize(m)
+	if !ok {
+		return fmt.Errorf("type %s is not of a fixed size", m)
 	}
-	return string(b), nil
+	if size == 0 {
+		return nil
+	}
+	for n > 0 {
+		// Avoid overflowing the byte count.
+		c := n
+		if c > math.MaxInt/size {
+			c = math.MaxInt / size
+		}
+		if err := r.discard(c * size); err != nil {
+			return err
+		}
+		n -= c
+	}
+	return nil
+}
+
+func (r *binaryReader) readBulk(m Marker, b []byte) error {
+	if _, err := io.ReadFull(r.Reader, b); err != nil {
+		return fmt.Errorf("failed to read %d bytes of '%s' values: %w", len(b), m, unexpectedEOF(err))
+	}
+	return nil
+}
+
+func (r *binaryReader) skipStringData(l int) error {
+	return r.discard(l)
+}
+
+// The discard method skips exactly n bytes.
+func (r *binaryReader) discard(n int) error {
+	if d, err := r.Discard(n); err != nil {
+		return fmt.Errorf("failed to skip %d bytes, only skipped %d: %w", n, d, unexpectedEOF(err))
+	}
+	return nil
 }
 
 func (r *binaryReader) readChar() (byte, error) {
 	b, err := r.ReadByte()
 	if err != nil {
-		return 0, errors.New("failed to read marker")
+		return 0, fmt.Errorf("failed to read Char byte: %w", unexpectedEOF(err))
 	}
 	if b > 127 {
-		return 0, fmt.Errorf("illegal Char value %d: must not exceed 127", b)
+		return 0, errSyntax("illegal Char value %d: must not exceed 127", b)
 	}
 	return b, nil
 }
@@ -264,11 +361,11 @@ func (r *blockReader) nextBlock() (string, error) {
 // The readBlock method reads the next block.
 func (r *blockReader) readBlock() (string, error) {
 	if _, err := r.ReadBytes('['); err != nil {
-		return "", fmt.Errorf("failed to read block start: %w", err)
+		return "", fmt.Errorf("failed to read block start: %w", unexpectedEOF(err))
 	}
 	s, err := r.ReadString(']')
 	if err != nil {
-		return "", errors.New("failed to read through block end")
+		return "", fmt.Errorf("failed to read through block end: %w", unexpectedEOF(err))
 	}
 	return s[:len(s)-1], nil
 }
@@ -281,7 +378,7 @@ func (r *blockReader) peekBlock() (string, error) {
 	n, err := r.readBlock()
 	if err == nil {
 		if n == "" {
-			return "", errors.New("empty block")
+			return "", errSyntax("empty block")
 		}
 		r.next = n
 	}
@@ -294,7 +391,7 @@ func (r *blockReader) readMarker() (Marker, error) {
 		return 0, err
 	}
 	if len(s) != 1 {
-		return 0, fmt.Errorf("expected single byte marker, but found: %q", s)
+		return 0, errSyntax("expected single byte marker, but found: %q", s)
 	}
 	return Marker(s[0]), nil
 }
@@ -305,7 +402,7 @@ func (r *blockReader) peekMarker() (Marker, error) {
 		return 0, err
 	}
 	if len(s) > 1 {
-		return 0, fmt.Errorf("expected single byte marker, but found %q", s)
+		return 0, errSyntax("expected single byte marker, but found %q", s)
 	}
 	return Marker(s[0]), nil
 }
@@ -317,7 +414,7 @@ func (r *blockReader) readUInt8() (uint8, error) {
 	}
 	i, err := strconv.ParseUint(s, 10, 8)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse uint8: %w", err)
+		return 0, errSyntax("failed to parse uint8: %w", err)
 	}
 	return uint8(i), nil
 }
@@ -339,7 +436,7 @@ func (r *blockReader) readBlockedInt(bitSize int) (int64, error) {
 	}
 	i, err := strconv.ParseInt(s, 10, bitSize)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse int%d: %w", bitSize, err)
+		return 0, errSyntax("failed to parse int%d: %w", bitSize, err)
 	}
 	return i, nil
 }
@@ -360,7 +457,10 @@ func (r *blockReader) readFloat32() (float32, error) {
 		return 0, err
 	}
 	f, err := strconv.ParseFloat(s, 32)
-	return float32(f), err
+	if err != nil {
+		return 0, errSyntax("failed to parse float32: %w", err)
+	}
+	return float32(f), nil
 }
 
 func (r *blockReader) readFloat64() (float64, error) {
@@ -369,43 +469,290 @@ func (r *blockReader) readFloat64() (float64, error) {
 		return 0, err
 	}
 	f, err := strconv.ParseFloat(s, 64)
-	return f, err
+	if err != nil {
+		return 0, errSyntax("failed to parse float64: %w", err)
+	}
+	return f, nil
 }
 
 func (r *blockReader) readString(max int) (string, error) {
-	l, err := readInt(r)
+	l, err := readStringLen(r, max)
 	if err != nil {
-		return "", fmt.Errorf("failed to read string length prefix: %w", err)
+		return "", err
 	}
-	switch {
-	case l == 0:
+	return r.readStringData(l)
+}
+
+func (r *blockReader) readStringData(l int) (string, error) {
+	if l == 0 {
 		return "", nil
-	case l < 0:
-		return "", fmt.Errorf("illegal string length prefix: %d", l)
-	case l > max:
-		return "", fmt.Errorf("string length prefix exceeds max allocation limit of %d: %d", max, l)
 	}
 	s, err := r.nextBlock()
 	if err != nil {
 		return "", fmt.Errorf("failed to read string block: %w", err)
 	}
 	if len(s) != l {
-		return "", fmt.Errorf("string length %d but prefix %d", len(s), l)
+		return "", errSyntax("string length %d but prefix %d", len(s), l)
 	}
 	return s, nil
 }
 
+func (r *blockReader) skipFixed(m Marker, n int) error {
+	size, ok := fixedSize(m)
+	if !ok {
+		return fmt.Errorf("type %s is not of a fixed size", m)
+	}
+	if size == 0 {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		if _, err := r.nextBlock(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *blockReader) readBulk(m Marker, b []byte) error {
+	size, ok := fixedSize(m)
+	if !ok || size == 0 {
+		return fmt.Errorf("type %s is not of a fixed size", m)
+	}
+	for ; len(b) >= size; b = b[size:] {
+		switch m {
+		case UInt8Marker:
+			v, err := r.readUInt8()
+			if err != nil {
+				return err
+			}
+			b[0] = v
+		case Int8Marker:
+			v, err := r.readInt8()
+			if err != nil {
+				return err
+			}
+			b[0] = uint8(v)
+		case Int16Marker:
+			v, err := r.readInt16()
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint16(b, uint16(v))
+		case Int32Marker:
+			v, err := r.readInt32()
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint32(b, uint32(v))
+		case Int64Marker:
+			v, err := r.readInt64()
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint64(b, uint64(v))
+		case Float32Marker:
+			v, err := r.readFloat32()
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint32(b, math.Float32bits(v))
+		case Float64Marker:
+			v, err := r.readFloat64()
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint64(b, math.Float64bits(v))
+		case CharMarker:
+			v, err := r.readChar()
+			if err != nil {
+				return err
+			}
+			b[0] = v
+		}
+	}
+	return nil
+}
+
+func (r *blockReader) skipStringData(l int) error {
+	_, err := r.readStringData(l)
+	return err
+}
+
 func (r *blockReader) readChar() (byte, error) {
 	s, err := r.nextBlock()
 	if err != nil {
 		return 0, err
 	}
 	if len(s) != 1 {
-		return 0, fmt.Errorf("expected single byte Char, but found: %q", s)
+		return 0, errSyntax("expected single byte Char, but found: %q", s)
 	}
 	b := s[0]
 	if b > 127 {
-		return 0, fmt.Errorf("illegal Char value %d: must not exceed 127", b)
+		return 0, errSyntax("illegal Char value %d: must not exceed 127", b)
+	}
+	return b, nil
+}
+
+// A bytesReader reads binary UBJSON directly from a byte slice.
+type bytesReader struct {
+	b   []byte
+	off int
+	// Return strings and byte arrays which alias b, rather than copies.
+	noCopy bool
+}
+
+func newBytesReader(b []byte, noCopy bool) *bytesReader {
+	return &bytesReader{b: b, noCopy: noCopy}
+}
+
+// The next method returns the next n bytes and advances. Like a stream, the
+// remaining bytes are consumed if fewer than n.
+func (r *bytesReader) next(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, io.ErrUnexpectedEOF
 	}
+	if n > len(r.b)-r.off {
+		r.off = len(r.b)
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
 	return b, nil
 }
+
+func (r *bytesReader) readMarker() (Marker, error) {
+	if r.off >= len(r.b) {
+		return 0, fmt.Errorf("failed to read marker: %w", io.ErrUnexpectedEOF)
+	}
+	m := Marker(r.b[r.off])
+	r.off++
+	return m, nil
+}
+
+func (r *bytesReader) peekMarker() (Marker, error) {
+	if r.off >= len(r.b) {
+		return 0, fmt.Errorf("failed to peek marker: %w", io.ErrUnexpectedEOF)
+	}
+	return Marker(r.b[r.off]), nil
+}
+
+func (r *bytesReader) readUInt8() (uint8, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read UInt8 byte: %w", err)
+	}
+	return b[0], nil
+}
+
+func (r *bytesReader) readInt8() (int8, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Int8 byte: %w", err)
+	}
+	return int8(b[0]), nil
+}
+
+func (r *bytesReader) readInt16() (int16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *bytesReader) readInt32() (int32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *bytesReader) readInt64() (int64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (r *bytesReader) readFloat32() (float32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *bytesReader) readFloat64() (float64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+}
+
+func (r *bytesReader) readString(max int) (string, error) {
+	l, err := readStringLen(r, max)
+	if err != nil {
+		return "", err
+	}
+	return r.readStringData(l)
+}
+
+func (r *bytesReader) readStringData(l int) (string, error) {
+	if l == 0 {
+		return "", nil
+	}
+	b, err := r.next(l)
+	if err != nil {
+		return "", fmt.Errorf("failed to read string bytes: %w", err)
+	}
+	if r.noCopy {
+		return unsafe.String(&b[0], l), nil
+	}
+	return string(b), nil
+}
+
+func (r *bytesReader) readChar() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Char byte: %w", err)
+	}
+	if b[0] > 127 {
+		return 0, errSyntax("illegal Char value %d: must not exceed 127", b[0])
+	}
+	return b[0], nil
+}
+
+func (r *bytesReader) skipFixed(m Marker, n int) error {
+	size, ok := fixedSize(m)
+	if !ok {
+		return fmt.Errorf("type %s is not of a fixed size", m)
+	}
+	if size == 0 {
+		return nil
+	}
+	if n > (len(r.b)-r.off)/size {
+		r.off = len(r.b)
+		return fmt.Errorf("failed to skip %d '%s' values: %w", n, m, io.ErrUnexpectedEOF)
+	}
+	r.off += n * size
+	return nil
+}
+
+func (r *bytesReader) skipStringData(l int) error {
+	if _, err := r.next(l); err != nil {
+		return fmt.Errorf("failed to skip string bytes: %w", err)
+	}
+	return nil
+}
+
+func (r *bytesReader) readBulk(m Marker, b []byte) error {
+	p, err := r.next(len(b))
+	if err != nil {
+		return fmt.Errorf("failed to read %d bytes of '%s' values: %w", len(b), m, err)
+	}
+	copy(b, p)
+	return nil
+}
@@ -0,0 +1,175 @@
+package ubjson
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// A TimeFormat selects the representation of time.Time and time.Duration
+// values. It may be set on Encoders and Decoders, and overridden per struct
+// field with the 'ubjson' tag options "text", "nanos", and "seconds".
+type TimeFormat uint8
+
+const (
+	// TimeText represents times as RFC 3339 strings (S) with nanosecond
+	// precision, and durations as Go duration strings (S), like "1h30m".
+	TimeText TimeFormat = iota
+	// TimeNanos represents times as nanoseconds since the Unix epoch, and
+	// durations as nanoseconds (L).
+	TimeNanos
+	// TimeSeconds represents times as seconds since the Unix epoch, and
+	// durations as seconds (D). Precision is limited by float64.
+	TimeSeconds
+)
+
+// timeFormatOptions are the 'ubjson' struct tag options for each TimeFormat.
+var timeFormatOptions = [...]string{
+	TimeText:    "text",
+	TimeNanos:   "nanos",
+	TimeSeconds: "seconds",
+}
+
+// minNanoTime and maxNanoTime bound the times which TimeNanos can represent,
+// from 1677 to 2262.
+var (
+	minNanoTime = time.Unix(0, math.MinInt64)
+	maxNanoTime = time.Unix(0, math.MaxInt64)
+)
+
+// EncodeTime encodes a time.Time according to the Encoder's TimeFormat. With
+// TimeNanos, times which overflow an int64 of nanoseconds return an error.
+func (e *Encoder) EncodeTime(t time.Time) error {
+	switch e.TimeFormat {
+	case TimeNanos:
+		if t.Before(minNanoTime) || t.After(maxNanoTime) {
+			return errOverflow(t, "int64 nanoseconds")
+		}
+		return e.EncodeInt64(t.UnixNano())
+	case TimeSeconds:
+		return e.EncodeFloat64(float64(t.Unix()) + float64(t.Nanosecond())/1e9)
+	default:
+		b, err := t.MarshalText()
+		if err != nil {
+			return fmt.Errorf("failed to format time: %w", err)
+		}
+		return e.EncodeString(string(b))
+	}
+}
+
+// EncodeDuration encodes a time.Duration according to the Encoder's
+// TimeFormat.
+func (e *Encoder) EncodeDuration(d time.Duration) error {
+	switch e.TimeFormat {
+	case TimeNanos:
+		return e.EncodeInt64(int64(d))
+	case TimeSeconds:
+		return e.EncodeFloat64(d.Seconds())
+	default:
+		return e.EncodeString(d.String())
+	}
+}
+
+// DecodeTime decodes a time.Time from an RFC 3339 string (S), from seconds
+// since the Unix epoch (d,D), or from an integer (U,i,I,l,L) of nanoseconds,
+// or of seconds if the Decoder's TimeFormat is TimeSeconds. Numeric times are
+// returned in UTC.
+func (d *Decoder) DecodeTime() (time.Time, error) {
+	t, err := d.decodeTime()
+	return t, d.locate(err)
+}
+
+func (d *Decoder) decodeTime() (time.Time, error) {
+	m, err := d.peekValType()
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch m {
+	case StringMarker:
+		s, err := d.DecodeString()
+		if err != nil {
+			return time.Time{}, err
+		}
+		var t time.Time
+		if err := t.UnmarshalText([]byte(s)); err != nil {
+			return time.Time{}, &UnmarshalTypeError{Marker: m, Type: timeType, msg: fmt.Sprintf("failed to parse time: %s", err)}
+		}
+		return t, nil
+	case Float32Marker, Float64Marker:
+		f, err := d.decodeFloat(m)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !fitsInt64(f) {
+			return time.Time{}, errOverflowRead(m, f, timeType)
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+		i, err := d.decodeSigned(m, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if d.TimeFormat == TimeSeconds {
+			return time.Unix(i, 0).UTC(), nil
+		}
+		return time.Unix(0, i).UTC(), nil
+	}
+	return time.Time{}, &UnmarshalTypeError{Marker: m, Type: timeType, msg: fmt.Sprintf("unable to decode time from type marker: %s", m)}
+}
+
+// DecodeDuration decodes a time.Duration from a Go duration string (S), from
+// seconds (d,D), or from an integer (U,i,I,l,L) of nanoseconds, or of seconds
+// if the Decoder's TimeFormat is TimeSeconds. Values which overflow a
+// time.Duration return an error.
+func (d *Decoder) DecodeDuration() (time.Duration, error) {
+	dur, err := d.decodeDuration()
+	return dur, d.locate(err)
+}
+
+func (d *Decoder) decodeDuration() (time.Duration, error) {
+	m, err := d.peekValType()
+	if err != nil {
+		return 0, err
+	}
+	switch m {
+	case StringMarker:
+		s, err := d.DecodeString()
+		if err != nil {
+			return 0, err
+		}
+		dur, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, &UnmarshalTypeError{Marker: m, Type: durationType, msg: fmt.Sprintf("failed to parse duration: %s", err)}
+		}
+		return dur, nil
+	case Float32Marker, Float64Marker:
+		f, err := d.decodeFloat(m)
+		if err != nil {
+			return 0, err
+		}
+		ns := f * float64(time.Second)
+		if !fitsInt64(ns) {
+			return 0, errOverflowRead(m, f, durationType)
+		}
+		return time.Duration(ns), nil
+	case UInt8Marker, Int8Marker, Int16Marker, Int32Marker, Int64Marker:
+		i, err := d.decodeSigned(m, 64)
+		if err != nil {
+			return 0, err
+		}
+		if d.TimeFormat == TimeSeconds {
+			if i > math.MaxInt64/int64(time.Second) || i < math.MinInt64/int64(time.Second) {
+				return 0, errOverflowRead(m, i, durationType)
+			}
+			return time.Duration(i) * time.Second, nil
+		}
+		return time.Duration(i), nil
+	}
+	return 0, &UnmarshalTypeError{Marker: m, Type: durationType, msg: fmt.Sprintf("unable to decode duration from type marker: %s", m)}
+}
+
+// fitsInt64 returns true if f is within the range of an int64.
+func fitsInt64(f float64) bool {
+	return f >= math.MinInt64 && f < math.MaxInt64
+}
@@ -0,0 +1,152 @@
+package ubjson
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	tm := time.Date(2023, 10, 1, 12, 30, 15, 250000000, time.UTC)
+	dur := 2*time.Hour + 250*time.Millisecond
+	for _, tt := range []struct {
+		name   string
+		format TimeFormat
+		marker Marker
+	}{
+		{"text", TimeText, StringMarker},
+		{"nanos", TimeNanos, Int64Marker},
+		{"seconds", TimeSeconds, Float64Marker},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			e.TimeFormat = tt.format
+			if err := e.Encode([]interface{}{tm, dur}); err != nil {
+				t.Fatal(err)
+			}
+			// Skip the array header.
+			if m := Marker(buf.Bytes()[4]); m != tt.marker {
+				t.Errorf("expected marker %s but got %s", tt.marker, m)
+			}
+
+			d := NewDecoder(&buf)
+			d.TimeFormat = tt.format
+			var got struct {
+				T time.Time
+				D time.Duration
+			}
+			err := d.DecodeArray(func(a *ArrayDecoder) error {
+				if err := a.Decode(&got.T); err != nil {
+					return err
+				}
+				if err := a.Decode(&got.D); err != nil {
+					return err
+				}
+				return a.End()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.T.Equal(tm) {
+				t.Errorf("expected time %s but got %s", tm, got.T)
+			}
+			if got.D != dur {
+				t.Errorf("expected duration %s but got %s", dur, got.D)
+			}
+		})
+	}
+}
+
+func TestDecoder_DecodeTime_seconds(t *testing.T) {
+	d := NewDecoder(bytes.NewReader([]byte{'l', 0x65, 0x19, 0x65, 0x37}))
+	d.TimeFormat = TimeSeconds
+	tm, err := d.DecodeTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := time.Unix(0x65196537, 0); !tm.Equal(exp) {
+		t.Errorf("expected %s but got %s", exp, tm)
+	}
+}
+
+func TestTimeFormat_bounds(t *testing.T) {
+	const maxSeconds = math.MaxInt64 / int64(time.Second)
+	for _, tt := range []struct {
+		name   string
+		encode func(e *Encoder) error
+		strict bool
+		// Expected time.Time or time.Duration, or nil for an overflow error.
+		exp      interface{}
+		duration bool
+	}{
+		{"float32-time-strict", func(e *Encoder) error { return e.EncodeFloat32(1e9) }, true, time.Unix(1e9, 0), false},
+		{"float32-duration-strict", func(e *Encoder) error { return e.EncodeFloat32(1.5) }, true, 1500 * time.Millisecond, true},
+		{"int32-time-strict", func(e *Encoder) error { return e.EncodeInt32(1e9) }, true, time.Unix(1e9, 0), false},
+		{"float-time-overflow", func(e *Encoder) error { return e.EncodeFloat64(1e19) }, false, nil, false},
+		{"float-time-nan", func(e *Encoder) error { return e.EncodeFloat64(math.NaN()) }, false, nil, false},
+		{"float-duration-overflow", func(e *Encoder) error { return e.EncodeFloat64(9.3e9) }, false, nil, true},
+		{"float-duration-underflow", func(e *Encoder) error { return e.EncodeFloat64(-9.3e9) }, false, nil, true},
+		{"seconds-duration-max", func(e *Encoder) error { return e.EncodeInt64(maxSeconds) }, false, time.Duration(maxSeconds) * time.Second, true},
+		{"seconds-duration-overflow", func(e *Encoder) error { return e.EncodeInt64(maxSeconds + 1) }, false, nil, true},
+		{"seconds-duration-underflow", func(e *Encoder) error { return e.EncodeInt64(-maxSeconds - 1) }, false, nil, true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.encode(NewEncoder(&buf)); err != nil {
+				t.Fatal(err)
+			}
+			d := NewDecoder(&buf)
+			d.TimeFormat = TimeSeconds
+			d.StrictNumbers = tt.strict
+			var got interface{}
+			var err error
+			if tt.duration {
+				got, err = d.DecodeDuration()
+			} else {
+				got, err = d.DecodeTime()
+			}
+			if tt.exp == nil {
+				var uerr *UnmarshalTypeError
+				if !errors.As(err, &uerr) {
+					t.Errorf("expected overflow *UnmarshalTypeError but got %v: %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tm, ok := tt.exp.(time.Time); ok {
+				if !got.(time.Time).Equal(tm) {
+					t.Errorf("expected %s but got %s", tm, got)
+				}
+			} else if got != tt.exp {
+				t.Errorf("expected %s but got %s", tt.exp, got)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name string
+		tm   time.Time
+		ok   bool
+	}{
+		{"max", maxNanoTime, true},
+		{"min", minNanoTime, true},
+		{"after-max", maxNanoTime.Add(time.Nanosecond), false},
+		{"before-min", minNanoTime.Add(-time.Nanosecond), false},
+		{"year-2300", time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"year-1600", time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC), false},
+	} {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.TimeFormat = TimeNanos
+		if err := e.EncodeTime(tt.tm); (err == nil) != tt.ok {
+			t.Errorf("nanos %s: expected ok=%t but got: %v", tt.name, tt.ok, err)
+		}
+	}
+}
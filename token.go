@@ -0,0 +1,306 @@
+package ubjson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A TokenKind identifies the kind of a Token.
+type TokenKind uint8
+
+const (
+	// ValueToken is a non-container value.
+	ValueToken TokenKind = iota
+	// KeyToken is an object key.
+	KeyToken
+	// ArrayStartToken begins an array container.
+	ArrayStartToken
+	// ArrayEndToken ends an array container.
+	ArrayEndToken
+	// ObjectStartToken begins an object container.
+	ObjectStartToken
+	// ObjectEndToken ends an object container.
+	ObjectEndToken
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case ValueToken:
+		return "value"
+	case KeyToken:
+		return "key"
+	case ArrayStartToken:
+		return "array start"
+	case ArrayEndToken:
+		return "array end"
+	case ObjectStartToken:
+		return "object start"
+	case ObjectEndToken:
+		return "object end"
+	}
+	return fmt.Sprintf("TokenKind(%d)", uint8(k))
+}
+
+// A Token is a single element of a UBJSON stream, as returned by
+// Decoder.Token and written by Encoder.WriteToken.
+type Token struct {
+	Kind TokenKind
+	// Type marker of a ValueToken.
+	Marker Marker
+	// Value of a ValueToken or KeyToken. Keys are strings, and values are
+	// decoded like interface{}: nil, bool, uint8, int8, int16, int32, int64,
+	// float32, float64, string, HighPrecNumber, or Char, or Number with
+	// Decoder.UseNumber.
+	Value interface{}
+	// Element type of an ArrayStartToken or ObjectStartToken, or 0 if not
+	// present.
+	ElemType Marker
+	// Number of elements or entries of an ArrayStartToken or
+	// ObjectStartToken, or -1 if not present.
+	Len int
+}
+
+// A tokenFrame tracks a container opened by Decoder.Token.
+type tokenFrame struct {
+	object   bool
+	elemType Marker
+	len      int
+	// Count of values read.
+	count int
+	// True after an object key has been read, and before its value.
+	value bool
+	// Current key, if keyed, for locating errors.
+	key   string
+	keyed bool
+}
+
+// Token returns the next Token. Containers are always ended with an
+// ArrayEndToken or ObjectEndToken, even when they have a length instead of an
+// end marker. Other decoding methods, like Decode and Skip, may be used in
+// place of Token to read entire values, but not keys.
+func (d *Decoder) Token() (Token, error) {
+	t, err := d.token()
+	return t, d.locate(err)
+}
+
+func (d *Decoder) token() (Token, error) {
+	if d.tokens == nil {
+		d.initTokens()
+	}
+	if n := len(d.tokens); n > 0 {
+		f := &d.tokens[n-1]
+		if !f.value {
+			end, err := d.tokenEnd(f)
+			if err != nil {
+				return Token{}, err
+			}
+			if end {
+				d.tokens = d.tokens[:n-1]
+				if f.object {
+					return Token{Kind: ObjectEndToken}, nil
+				}
+				return Token{Kind: ArrayEndToken}, nil
+			}
+			if f.object {
+				f.keyed = false
+				k, err := d.readString(d.MaxCollectionAlloc)
+				if err != nil {
+					return Token{}, fmt.Errorf("failed to read key: %w", err)
+				}
+				f.value, f.key, f.keyed = true, k, true
+				return Token{Kind: KeyToken, Value: k}, nil
+			}
+		}
+	}
+
+	m, err := d.readValType()
+	if err != nil {
+		return Token{}, err
+	}
+	switch m {
+	case ArrayStartMarker, ObjectStartMarker:
+		if err := d.checkDepth(); err != nil {
+			return Token{}, err
+		}
+		t, l, err := readContainer(d.reader)
+		if err != nil {
+			return Token{}, err
+		}
+		if t == NoOpMarker {
+			return Token{}, errSyntax("No-Op (N) is not a legal strong type")
+		}
+		object := m == ObjectStartMarker
+		d.tokens = append(d.tokens, tokenFrame{object: object, elemType: t, len: l})
+		if object {
+			return Token{Kind: ObjectStartToken, ElemType: t, Len: l}, nil
+		}
+		return Token{Kind: ArrayStartToken, ElemType: t, Len: l}, nil
+	}
+	v, err := d.readScalar(m)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Kind: ValueToken, Marker: m, Value: v}, nil
+}
+
+// PeekType returns the type marker of the next value without advancing. In
+// strongly typed containers, this is the container's type. At the end of a
+// container without a length, it is the end marker (']' or '}').
+func (d *Decoder) PeekType() (Marker, error) {
+	m, err := d.peekValType()
+	return m, d.locate(err)
+}
+
+// initTokens wraps readValType and peekValType to account for containers
+// opened by Token.
+func (d *Decoder) initTokens() {
+	d.tokens = make([]tokenFrame, 0, 8)
+	read, peek := d.readValType, d.peekValType
+	d.readValType = func() (Marker, error) {
+		n := len(d.tokens)
+		if n == 0 {
+			return read()
+		}
+		f := &d.tokens[n-1]
+		if f.object {
+			if !f.value {
+				return 0, errors.New("unable to decode value: expected key")
+			}
+			f.value = false
+		}
+		f.count++
+		if f.len >= 0 && f.count > f.len {
+			return 0, errTooMany(f.len)
+		}
+		if err := d.addElements(1); err != nil {
+			return 0, err
+		}
+		if f.elemType != 0 {
+			return f.elemType, nil
+		}
+		return d.readMarker()
+	}
+	d.peekValType = func() (Marker, error) {
+		n := len(d.tokens)
+		if n == 0 {
+			return peek()
+		}
+		if f := &d.tokens[n-1]; f.elemType != 0 && (f.len < 0 || f.count < f.len) {
+			return f.elemType, nil
+		}
+		return d.peekMarker()
+	}
+}
+
+// tokenEnd returns true if the container f has ended, consuming the end
+// marker if present.
+func (d *Decoder) tokenEnd(f *tokenFrame) (bool, error) {
+	if f.len >= 0 {
+		return f.count >= f.len, nil
+	}
+	end := arrayEndMarker
+	if f.object {
+		end = objectEndMarker
+	}
+	m, err := d.peekMarker()
+	if err != nil {
+		return false, err
+	}
+	if m != end {
+		return false, nil
+	}
+	_, err = d.readMarker()
+	return true, err
+}
+
+// A tokenContainer tracks a container opened by Encoder.WriteToken.
+type tokenContainer struct {
+	a *ArrayEncoder
+	o *ObjectEncoder
+}
+
+// WriteToken writes a Token. Containers are written with the ElemType and Len
+// of their start tokens, and must be ended with a matching end token. Values
+// are written like Encode, except for the No-Op (N) marker.
+func (e *Encoder) WriteToken(t Token) error {
+	cur := e
+	var top *tokenContainer
+	if n := len(e.tokens); n > 0 {
+		top = &e.tokens[n-1]
+		if top.a != nil {
+			cur = &top.a.Encoder
+		} else {
+			cur = &top.o.Encoder
+		}
+	}
+
+	switch t.Kind {
+	case ValueToken:
+		if t.Marker == NoOpMarker {
+			return cur.EncodeNoOp()
+		}
+		return cur.Encode(t.Value)
+
+	case KeyToken:
+		if top == nil || top.o == nil {
+			return errors.New("unable to write key outside of object")
+		}
+		k, ok := t.Value.(string)
+		if !ok {
+			return fmt.Errorf("unable to write key of type %T", t.Value)
+		}
+		return top.o.EncodeKey(k)
+
+	case ArrayStartToken:
+		if err := cur.writeValType(ArrayStartMarker); err != nil {
+			return err
+		}
+		a, err := cur.ArrayType(t.ElemType, t.Len)
+		if err != nil {
+			return err
+		}
+		e.tokens = append(e.tokens, tokenContainer{a: a})
+		return nil
+
+	case ObjectStartToken:
+		if err := cur.writeValType(ObjectStartMarker); err != nil {
+			return err
+		}
+		o, err := cur.ObjectType(t.ElemType, t.Len)
+		if err != nil {
+			return err
+		}
+		e.tokens = append(e.tokens, tokenContainer{o: o})
+		return nil
+
+	case ArrayEndToken:
+		if top == nil || top.a == nil {
+			return errors.New("unable to end array: no array open")
+		}
+		e.tokens = e.tokens[:len(e.tokens)-1]
+		if err := top.a.End(); err != nil {
+			return err
+		}
+		return e.flushToken()
+
+	case ObjectEndToken:
+		if top == nil || top.o == nil {
+			return errors.New("unable to end object: no object open")
+		}
+		e.tokens = e.tokens[:len(e.tokens)-1]
+		if err := top.o.End(); err != nil {
+			return err
+		}
+		return e.flushToken()
+	}
+	return fmt.Errorf("unable to write token of kind %s", t.Kind)
+}
+
+// flushToken flushes after the end of a top-level container, like the end of
+// any other top-level value.
+func (e *Encoder) flushToken() error {
+	if len(e.tokens) > 0 || e.depth > 0 || e.ManualFlush {
+		return nil
+	}
+	return e.Flush()
+}
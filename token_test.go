@@ -0,0 +1,149 @@
+package ubjson
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// copyTokens copies all tokens of a single value from d to e.
+func copyTokens(d *Decoder, e *Encoder) error {
+	depth := 0
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if err := e.WriteToken(t); err != nil {
+			return err
+		}
+		switch t.Kind {
+		case ArrayStartToken, ObjectStartToken:
+			depth++
+		case ArrayEndToken, ObjectEndToken:
+			depth--
+		}
+		if depth == 0 && t.Kind != KeyToken {
+			return nil
+		}
+	}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	t.Parallel()
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if err := copyTokens(NewDecoder(bytes.NewReader(tc.binary)), NewEncoder(&buf)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), tc.binary) {
+				t.Errorf("expected:\n%#v\nbut got:\n%#v", tc.binary, buf.Bytes())
+			}
+
+			buf.Reset()
+			if err := copyTokens(NewBlockDecoder(strings.NewReader(tc.block)), NewBlockEncoder(&buf)); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tc.block {
+				t.Errorf("expected:\n%s\nbut got:\n%s", tc.block, buf.String())
+			}
+		})
+	}
+}
+
+func TestDecoder_Token(t *testing.T) {
+	bin := []byte{'{',
+		'U', 1, 'a', '[', '$', 'i', '#', 'U', 2, 0xFF, 0x01,
+		'U', 1, 'b', '{', '#', 'U', 1, 'U', 1, 'c', 'Z',
+		'U', 1, 'd', 'N',
+		'}'}
+	exp := []Token{
+		{Kind: ObjectStartToken, Len: -1},
+		{Kind: KeyToken, Value: "a"},
+		{Kind: ArrayStartToken, ElemType: Int8Marker, Len: 2},
+		{Kind: ValueToken, Marker: Int8Marker, Value: int8(-1)},
+		{Kind: ValueToken, Marker: Int8Marker, Value: int8(1)},
+		{Kind: ArrayEndToken},
+		{Kind: KeyToken, Value: "b"},
+		{Kind: ObjectStartToken, Len: 1},
+		{Kind: KeyToken, Value: "c"},
+		{Kind: ValueToken, Marker: NullMarker},
+		{Kind: ObjectEndToken},
+		{Kind: KeyToken, Value: "d"},
+		{Kind: ValueToken, Marker: NoOpMarker},
+		{Kind: ObjectEndToken},
+	}
+	d := NewDecoder(bytes.NewReader(bin))
+	for i, e := range exp {
+		got, err := d.Token()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if !reflect.DeepEqual(e, got) {
+			t.Errorf("%d: expected %+v but got %+v", i, e, got)
+		}
+	}
+	if _, err := d.Token(); err == nil {
+		t.Error("expected error at end of input")
+	}
+}
+
+func TestDecoder_Token_mixed(t *testing.T) {
+	bin := []byte{'[', '$', 'S', '#', 'U', 3, 'U', 1, 'a', 'U', 1, 'b', 'U', 1, 'c'}
+	d := NewDecoder(bytes.NewReader(bin))
+	if tok, err := d.Token(); err != nil {
+		t.Fatal(err)
+	} else if tok.Kind != ArrayStartToken {
+		t.Fatalf("expected array start but got %s", tok.Kind)
+	}
+	if m, err := d.PeekType(); err != nil {
+		t.Fatal(err)
+	} else if m != StringMarker {
+		t.Errorf("expected peeked type %s but got %s", StringMarker, m)
+	}
+	var s string
+	if err := d.Decode(&s); err != nil {
+		t.Fatal(err)
+	} else if s != "a" {
+		t.Errorf("expected %q but got %q", "a", s)
+	}
+	if err := d.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if tok, err := d.Token(); err != nil {
+		t.Fatal(err)
+	} else if tok.Value != "c" {
+		t.Errorf("expected %q but got %v", "c", tok.Value)
+	}
+	if tok, err := d.Token(); err != nil {
+		t.Fatal(err)
+	} else if tok.Kind != ArrayEndToken {
+		t.Errorf("expected array end but got %s", tok.Kind)
+	}
+}
+
+func TestEncoder_WriteToken_errors(t *testing.T) {
+	for _, tokens := range [][]Token{
+		{{Kind: KeyToken, Value: "a"}},
+		{{Kind: ArrayEndToken}},
+		{{Kind: ArrayStartToken, Len: -1}, {Kind: ObjectEndToken}},
+		{{Kind: ObjectStartToken, Len: -1}, {Kind: KeyToken, Value: 1}},
+		{{Kind: ArrayStartToken, ElemType: StringMarker, Len: 1}, {Kind: ValueToken, Value: 1}},
+	} {
+		e := NewEncoder(io.Discard)
+		var err error
+		for _, tok := range tokens {
+			if err = e.WriteToken(tok); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("expected error for tokens %+v", tokens)
+		}
+	}
+}
@@ -4,7 +4,9 @@
 // Most types can be automatically encoded through reflection with the Marshal
 // and Unmarshal functions. Encoders and Decoders additionally provide type
 // specific methods. Custom encodings can be defined by implementing the Value
-// interface. 'ubjson' struct tags can be used to override field names.
+// interface. 'ubjson' struct tags can be used to override field names, skip
+// fields ("-"), and set the "omitempty" and "string" options, with the same
+// semantics as encoding/json.
 //
 //	b, _ := ubjson.MarshalBlock(8)
 //	// [U][8]
@@ -17,8 +19,6 @@
 //
 package ubjson
 
-import "bytes"
-
 // The Value interface defines a custom encoding.
 type Value interface {
 	// The type marker for this kind of value. Must always return the same value.
@@ -30,35 +30,52 @@ type Value interface {
 }
 
 // Marshal encodes a value into UBJSON. Types implementing Value will be encoded
-// with their UBJSONType and MarshalUBJSON methods.
+// with their UBJSONType and MarshalUBJSON methods. Map entries are sorted by
+// key, so equal values always produce identical output.
 func Marshal(v interface{}) ([]byte, error) {
-	var b bytes.Buffer
-	if err := NewEncoder(&b).Encode(v); err != nil {
+	es := getEncodeState(false)
+	defer putEncodeState(es, false)
+	if err := es.e.Encode(v); err != nil {
 		return nil, err
 	}
-	return b.Bytes(), nil
+	return append([]byte(nil), es.Bytes()...), nil
 }
 
 // MarshalBlock encodes a value into UBJSON block-notation. Types implementing
-// Value will be encoded with their UBJSONType and MarshalUBJSON methods.
+// Value will be encoded with their UBJSONType and MarshalUBJSON methods. Map
+// entries are sorted by key.
 func MarshalBlock(v interface{}) ([]byte, error) {
-	var b bytes.Buffer
-	if err := NewBlockEncoder(&b).Encode(v); err != nil {
+	es := getEncodeState(true)
+	defer putEncodeState(es, true)
+	if err := es.e.Encode(v); err != nil {
 		return nil, err
 	}
-	return b.Bytes(), nil
+	return append([]byte(nil), es.Bytes()...), nil
 }
 
 // Unmarshal decodes a value from UBJSON. Types implementing Value will be
 // decoded via their UBJSONType and UnmarshalUBJSON methods.
 func Unmarshal(binary []byte, v interface{}) error {
-	return NewDecoder(bytes.NewReader(binary)).Decode(v)
+	d := getBytesDecoder(binary, false)
+	defer putBytesDecoder(d)
+	return requireValue(d.Decode(v))
+}
+
+// UnmarshalNoCopy is like Unmarshal, except that decoded strings and byte
+// arrays alias binary instead of being copied, so binary must not be modified
+// afterwards.
+func UnmarshalNoCopy(binary []byte, v interface{}) error {
+	d := getBytesDecoder(binary, true)
+	defer putBytesDecoder(d)
+	return requireValue(d.Decode(v))
 }
 
 // UnmarshalBlock decodes a value from UBJSON block-notation. Types implementing
 // Value will be encoded with their UBJSONType and MarshalUBJSON methods.
 func UnmarshalBlock(block []byte, v interface{}) error {
-	return NewBlockDecoder(bytes.NewReader(block)).Decode(v)
+	ds := getBlockDecodeState(block)
+	defer putBlockDecodeState(ds)
+	return requireValue(ds.d.Decode(v))
 }
 
 // A Char is a byte which is encoded as 'C' instead of 'U'. Must be <=127.
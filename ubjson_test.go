@@ -1,21 +1,11 @@
 package ubjson
 
 import (
-	"reflect"
-	"sort"
+	"fmt"
+	"net"
+	"time"
 )
 
-func init() {
-	// Force deterministic ordering to reflective map key traversal.
-	mapKeys = func(m reflect.Value) []reflect.Value {
-		vs := m.MapKeys()
-		sort.Slice(vs, func(i, j int) bool {
-			return vs[i].String() < vs[j].String()
-		})
-		return vs
-	}
-}
-
 type testCase struct {
 	value  interface{}
 	binary []byte
@@ -27,6 +17,14 @@ var cases = map[string]testCase{
 	"Int=255": {int(255), []byte{'U', 0xFF}, "[U][255]"},
 	"UInt=80": {uint8(0), []byte{'U', 0x00}, "[U][0]"},
 
+	"UInt=0":            {uint(0), []byte{'U', 0x00}, "[U][0]"},
+	"UInt16=300":        {uint16(300), []byte{'I', 0x01, 0x2C}, "[I][300]"},
+	"UInt32=4294967295": {uint32(4294967295), []byte{0: 'L', 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}, "[L][4294967295]"},
+	"UInt64=32768":      {uint64(32768), []byte{'l', 0x00, 0x00, 0x80, 0x00}, "[l][32768]"},
+	"Uintptr=255":       {uintptr(255), []byte{'U', 0xFF}, "[U][255]"},
+	"UInt64=max": {uint64(18446744073709551615), append([]byte{'H', 0x55, 0x14}, "18446744073709551615"...),
+		"[H][U][20][18446744073709551615]"},
+
 	"Int=-128": {int(-128), []byte{'i', 0x80}, "[i][-128]"},
 	"Int8=127": {int8(127), []byte{'i', 0x7F}, "[i][127]"},
 
@@ -54,6 +52,27 @@ var cases = map[string]testCase{
 	"string=string": {"string", append([]byte{'S', 0x55, 0x06}, "string"...), "[S][U][6][string]"},
 	"string=empty":  {"", []byte{'S', 0x55, 0x00}, "[S][U][0]"},
 
+	"String=text-marshaler": {net.ParseIP("10.0.0.1"), append([]byte{'S', 0x55, 0x08}, "10.0.0.1"...), "[S][U][8][10.0.0.1]"},
+	"Array-String=text-marshaler": {[]net.IP{net.ParseIP("::1")}, append([]byte{'[', '$', 'S', '#', 'U', 0x01, 0x55, 0x03}, "::1"...),
+		"[[][$][S][#][U][1]\n\t[U][3][::1]"},
+	"Array-UInt8=binary-marshaler": {binaryPair{1, 2}, []byte{'[', '$', 'U', '#', 'U', 0x02, 0x01, 0x02},
+		"[[][$][U][#][U][2]\n\t[1]\n\t[2]"},
+
+	"String=time": {time.Date(2023, 10, 1, 12, 0, 0, 5, time.UTC), append([]byte{'S', 0x55, 0x1E}, "2023-10-01T12:00:00.000000005Z"...),
+		"[S][U][30][2023-10-01T12:00:00.000000005Z]"},
+	"String=duration": {90 * time.Minute, append([]byte{'S', 0x55, 0x07}, "1h30m0s"...), "[S][U][7][1h30m0s]"},
+	"Object=struct-time-options": {
+		struct {
+			T time.Time     `ubjson:"t,nanos"`
+			D time.Duration `ubjson:"d,seconds"`
+		}{time.Unix(0, 1500).UTC(), 1500 * time.Millisecond},
+		[]byte{'{',
+			'U', 0x01, 't', 'L', 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0xDC,
+			'U', 0x01, 'd', 'D', 0x3F, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			'}'},
+		"[{]\n\t[U][1][t][L][1500]\n\t[U][1][d][D][1.5]\n[}]",
+	},
+
 	"Array-empty": {[0]int{}, []byte{0x5b, 0x23, 0x55, 0x0}, "[[][#][U][0]"},
 
 	"Slice-empty": {[]int{}, []byte{0x5b, 0x23, 0x55, 0x0}, "[[][#][U][0]"},
@@ -76,6 +95,24 @@ var cases = map[string]testCase{
 			'U', 0x01, 'a', 0x05},
 		"[{][$][i][#][U][1]\n\t[U][1][a][5]",
 	},
+	"Object-Int8=map-int-key": {
+		map[int]int8{-3: 5},
+		[]byte{'{', '$', 'i', '#', 'U', 0x01,
+			'U', 0x02, '-', '3', 0x05},
+		"[{][$][i][#][U][1]\n\t[U][2][-3][5]",
+	},
+	"Object-String=map-uint16-key": {
+		map[uint16]string{300: "a"},
+		[]byte{'{', '$', 'S', '#', 'U', 0x01,
+			'U', 0x03, '3', '0', '0', 'U', 0x01, 'a'},
+		"[{][$][S][#][U][1]\n\t[U][3][300][U][1][a]",
+	},
+	"Object-UInt8=map-text-key": {
+		map[textKey]uint8{{1, 2}: 3},
+		[]byte{'{', '$', 'U', '#', 'U', 0x01,
+			'U', 0x03, '1', '-', '2', 0x03},
+		"[{][$][U][#][U][1]\n\t[U][3][1-2][3]",
+	},
 	"Object-Int8=struct": {
 		struct {
 			A int8
@@ -99,6 +136,47 @@ var cases = map[string]testCase{
 			'}'},
 		"[{]\n\t[U][1][a][i][5]\n\t[U][1][b][i][8]\n[}]",
 	},
+	"Object=struct-tag-options": {
+		struct {
+			A int8  `ubjson:"a,omitempty"`
+			B int8  `ubjson:",omitempty"`
+			C int16 `ubjson:"-"`
+			D int32 `ubjson:"d,string"`
+			E bool  `ubjson:"-,"`
+		}{0, 5, 0, 42, true},
+		[]byte{'{',
+			'U', 0x01, 'B', 'i', 0x05,
+			'U', 0x01, 'd', 'S', 'U', 0x02, '4', '2',
+			'U', 0x01, '-', 'T',
+			'}'},
+		"[{]\n\t[U][1][B][i][5]\n\t[U][1][d][S][U][2][42]\n\t[U][1][-][T]\n[}]",
+	},
+	"Object=struct-embedded": {
+		embeddingStruct{embeddedBase: embeddedBase{ID: 1}, EmbeddedHeader: &EmbeddedHeader{Version: 2}, Name: "top"},
+		[]byte{'{',
+			'U', 0x02, 'I', 'D', 'i', 0x01,
+			'U', 0x07, 'V', 'e', 'r', 's', 'i', 'o', 'n', 'i', 0x02,
+			'U', 0x04, 'N', 'a', 'm', 'e', 'S', 'U', 0x03, 't', 'o', 'p',
+			'}'},
+		"[{]\n\t[U][2][ID][i][1]\n\t[U][7][Version][i][2]\n\t[U][4][Name][S][U][3][top]\n[}]",
+	},
+	"Object=struct-pointers": {
+		pointerStruct{I: int8Ptr(5), S: []*int8{int8Ptr(6), nil}},
+		[]byte{'{',
+			'U', 0x01, 'I', 'i', 0x05,
+			'U', 0x01, 'N', 'Z',
+			'U', 0x01, 'S', '[', '#', 'U', 0x02, 'i', 0x06, 'Z',
+			'}'},
+		"[{]\n\t[U][1][I][i][5]\n\t[U][1][N][Z]\n\t[U][1][S][[][#][U][2]\n\t\t[i][6]\n\t\t[Z]\n[}]",
+	},
+	"Object=struct-string-pointers": {
+		stringPointerStruct{I: int8Ptr(42)},
+		[]byte{'{',
+			'U', 0x01, 'I', 'S', 'U', 0x02, '4', '2',
+			'U', 0x01, 'N', 'Z',
+			'}'},
+		"[{]\n\t[U][1][I][S][U][2][42]\n\t[U][1][N][Z]\n[}]",
+	},
 
 	"Object=complex-struct": {complexStruct, complexStructBinary, complexStructBlock},
 	"Object=complex-map":    {complexMap, complexMapBinary, complexMapBlock},
@@ -110,6 +188,68 @@ var cases = map[string]testCase{
 		"[[][$][H][#][U][1]\n\t[U][11][3.402823e38]"},
 }
 
+type embeddedBase struct {
+	ID   int8
+	Name string // Hidden by embeddingStruct.Name.
+}
+
+type EmbeddedHeader struct {
+	Version int8
+}
+
+type embeddingStruct struct {
+	embeddedBase
+	*EmbeddedHeader
+	Name string
+}
+
+// A textKey is a map key implementing encoding.TextMarshaler and
+// encoding.TextUnmarshaler.
+type textKey struct {
+	a, b int
+}
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d-%d", k.a, k.b)), nil
+}
+
+func (k *textKey) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d-%d", &k.a, &k.b)
+	return err
+}
+
+// A binaryPair implements encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler.
+type binaryPair struct {
+	a, b uint8
+}
+
+func (p binaryPair) MarshalBinary() ([]byte, error) {
+	return []byte{p.a, p.b}, nil
+}
+
+func (p *binaryPair) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("expected 2 bytes but got %d", len(data))
+	}
+	p.a, p.b = data[0], data[1]
+	return nil
+}
+
+type pointerStruct struct {
+	I *int8
+	N *string
+	S []*int8
+}
+
+// A stringPointerStruct has pointer fields with the 'string' tag option.
+type stringPointerStruct struct {
+	I *int8 `ubjson:",string"`
+	N *bool `ubjson:",string"`
+}
+
+func int8Ptr(i int8) *int8 { return &i }
+
 type complexType struct {
 	Location            string
 	Email               string
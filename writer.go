@@ -2,6 +2,7 @@ package ubjson
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -22,24 +23,71 @@ type writer interface {
 	writeFloat64(float64) error
 	// Writes a length-prefixed UBJSON string.
 	writeString(string) error
+	// Writes the data of a UBJSON string, following the length prefix.
+	writeStringData(string) error
 	// Writes a UBJSON Char, which must be <=127.
 	writeChar(byte) error
+	// Writes the data of consecutive values of fixed size type m from b, as
+	// big-endian binary.
+	writeBulk(m Marker, b []byte) error
 	Flush() error
 	writeNewLine() error
 	incIndent()
 	decIndent()
 }
 
+// A bufferedWriter is satisfied by *bufio.Writer, and by *bytes.Buffer via
+// bytesWriter.
+type bufferedWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+	Flush() error
+}
+
+// A bytesWriter is a bufferedWriter which writes directly to a bytes.Buffer.
+type bytesWriter struct {
+	*bytes.Buffer
+}
+
+func (bytesWriter) Flush() error { return nil }
+
+// The newBufferedWriter function returns a bufferedWriter for w, with a buffer
+// of at least size bytes if size is positive, or the default size otherwise.
+// A *bytes.Buffer is written to directly.
+func newBufferedWriter(w io.Writer, size int) bufferedWriter {
+	if b, ok := w.(*bytes.Buffer); ok {
+		return bytesWriter{b}
+	}
+	if size > 0 {
+		return bufio.NewWriterSize(w, size)
+	}
+	return bufio.NewWriter(w)
+}
+
+// The resetBufferedWriter function returns a bufferedWriter for w, which reuses
+// b if it is a *bufio.Writer, or else has a buffer of at least size bytes like
+// newBufferedWriter.
+func resetBufferedWriter(b bufferedWriter, w io.Writer, size int) bufferedWriter {
+	if _, ok := w.(*bytes.Buffer); !ok {
+		if bw, ok := b.(*bufio.Writer); ok {
+			bw.Reset(w)
+			return bw
+		}
+	}
+	return newBufferedWriter(w, size)
+}
+
 // A blockWriter is a writer which writes block-notation UBJSON.
 type blockWriter struct {
-	*bufio.Writer
+	bufferedWriter
 	// Current number of indentations.
 	indent int
 }
 
 // The newBlockWriter function returns a new block-notation writer.
-func newBlockWriter(w io.Writer) *blockWriter {
-	return &blockWriter{Writer: bufio.NewWriter(w)}
+func newBlockWriter(w io.Writer, size int) *blockWriter {
+	return &blockWriter{bufferedWriter: newBufferedWriter(w, size)}
 }
 
 // The writeBlocked method writes s surrounded by square brackets.
@@ -121,23 +169,62 @@ func (w *blockWriter) writeString(s string) error {
 	if err := writeInt(w, len(s)); err != nil {
 		return fmt.Errorf("failed writing string lenth prefix: %w", err)
 	}
+	return w.writeStringData(s)
+}
 
+func (w *blockWriter) writeStringData(s string) error {
 	if len(s) > 0 {
 		return w.writeBlocked(s)
 	}
 	return nil
 }
 
+// The writeBulk method writes each value on a new line.
+func (w *blockWriter) writeBulk(m Marker, b []byte) error {
+	size, ok := fixedSize(m)
+	if !ok || size == 0 {
+		return fmt.Errorf("type %s is not of a fixed size", m)
+	}
+	for ; len(b) >= size; b = b[size:] {
+		if err := w.writeNewLine(); err != nil {
+			return err
+		}
+		var err error
+		switch m {
+		case UInt8Marker:
+			err = w.writeUInt8(b[0])
+		case Int8Marker:
+			err = w.writeInt8(int8(b[0]))
+		case Int16Marker:
+			err = w.writeInt16(int16(binary.BigEndian.Uint16(b)))
+		case Int32Marker:
+			err = w.writeInt32(int32(binary.BigEndian.Uint32(b)))
+		case Int64Marker:
+			err = w.writeInt64(int64(binary.BigEndian.Uint64(b)))
+		case Float32Marker:
+			err = w.writeFloat32(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		case Float64Marker:
+			err = w.writeFloat64(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		case CharMarker:
+			err = w.writeChar(b[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // A binaryWriter is a writer which writes binary UBJSON.
 type binaryWriter struct {
-	*bufio.Writer
+	bufferedWriter
 
 	// A buffer as large as the largest fixed size type.
 	buf [8]byte
 }
 
-func newBinaryWriter(w io.Writer) *binaryWriter {
-	return &binaryWriter{Writer: bufio.NewWriter(w)}
+func newBinaryWriter(w io.Writer, size int) *binaryWriter {
+	return &binaryWriter{bufferedWriter: newBufferedWriter(w, size)}
 }
 
 func (w *binaryWriter) writeNewLine() error { return nil }
@@ -201,13 +288,27 @@ func (w *binaryWriter) writeChar(v byte) error {
 	return w.writeByte(byte(v))
 }
 
+func (w *binaryWriter) writeBulk(m Marker, b []byte) error {
+	return w.write(b)
+}
+
 func (w *binaryWriter) writeString(s string) error {
-	if err := writeInt(w, len(s)); err != nil {
+	if len(s) <= math.MaxUint8 {
+		// Common case of a 'U' length prefix, written at once.
+		b := w.buf[:2]
+		b[0], b[1] = byte(UInt8Marker), uint8(len(s))
+		if err := w.write(b); err != nil {
+			return fmt.Errorf("failed writing string lenth prefix: %w", err)
+		}
+	} else if err := writeInt(w, len(s)); err != nil {
 		return fmt.Errorf("failed writing string lenth prefix: %w", err)
 	}
+	return w.writeStringData(s)
+}
 
+func (w *binaryWriter) writeStringData(s string) error {
 	if len(s) > 0 {
-		_, err := io.WriteString(w, s)
+		_, err := w.WriteString(s)
 		return err
 	}
 	return nil